@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printAuth prints only the credentials of the user of the single context
+// of the minified config, as yaml or json. The certificate data is printed
+// as plain PEM instead of base64 when decode is set
+func printAuth(w io.Writer, cfg *Config, format string, decode bool) error {
+	if len(cfg.Contexts) != 1 {
+		return errors.New("-only-auth needs a single context")
+	}
+	user := cfg.FindUser(cfg.Contexts[0].Context.User)
+
+	var root yaml.Node
+	if err := root.Encode(user.User); err != nil {
+		return err
+	}
+	if decode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			if !strings.HasSuffix(key.Value, "-data") {
+				continue
+			}
+			data, err := decodeB64(value.Value)
+			if err != nil {
+				return err
+			}
+			value.Value = string(data)
+			value.Style = yaml.LiteralStyle
+		}
+	}
+
+	if format == "json" {
+		var auth map[string]interface{}
+		if err := root.Decode(&auth); err != nil {
+			return err
+		}
+		return printJSON(w, auth)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(indent)
+	if err := enc.Encode(&root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
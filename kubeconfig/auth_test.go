@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOnlyAuth(t *testing.T) {
+	tokens := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", tokens, "-c", "prod", "-only-auth")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "token: bob-token\n" {
+		t.Errorf("got %q", stdout)
+	}
+
+	fname, _ := fileRefConfig(t)
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-only-auth", "-format", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var auth map[string]string
+	if err = json.Unmarshal([]byte(stdout), &auth); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout)
+	}
+	if len(auth) != 2 || auth["client-certificate-data"] != base64.StdEncoding.EncodeToString([]byte("cert")) {
+		t.Errorf("got %v", auth)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-only-auth", "-decode")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "client-certificate-data: |-\n  cert\nclient-key-data: |-\n  key\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-c", "*", "-only-auth"}, "-only-auth needs a single context"},
+		{[]string{"-c", "dev", "-only-auth", "-format", "env"}, "-format env cannot be used with -only-auth or -fields"},
+	} {
+		if _, stderr, err = runMain(t, append([]string{"-f", tokens}, tt.args...)...); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
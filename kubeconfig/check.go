@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+func tlsConfig(cluster ClusterInfo, user UserInfo) (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         cluster.TLSServerName,
+		InsecureSkipVerify: cluster.InsecureSkipTLSVerify,
+	}
+
+	ca, err := dataOrFile(cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err != nil {
+		return nil, err
+	}
+	if len(ca) > 0 {
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no valid certificate found in the certificate authority")
+		}
+	}
+
+	cert, err := dataOrFile(user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return nil, err
+	}
+	key, err := dataOrFile(user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(cert) > 0 && len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+	return conf, nil
+}
+
+// checkKeyPair checks that the client certificate of the user matches its
+// key, the users without both are skipped
+func checkKeyPair(user UserInfo) error {
+	cert, err := dataOrFile(user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := dataOrFile(user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return err
+	}
+	if len(cert) == 0 || len(key) == 0 {
+		return nil
+	}
+	_, err = tls.X509KeyPair(cert, key)
+	return err
+}
+
+// serverAddress returns the host:port to dial for the server url
+func serverAddress(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" {
+		return "", errors.New("server is not an https url")
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	}
+	return u.Host, nil
+}
+
+// checkConnectivity does a tls handshake with the server of the cluster,
+// using its certificate authority and the client certificate of the user
+func checkConnectivity(ctx context.Context, cluster ClusterInfo, user UserInfo) error {
+	addr, err := serverAddress(cluster.Server)
+	if err != nil {
+		return err
+	}
+	conf, err := tlsConfig(cluster, user)
+	if err != nil {
+		return err
+	}
+	conn, err := dialTLS(ctx, cluster, addr, conf)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// rootPool returns the pool of the certificate authority, added to a copy
+// of the system roots when withSystem is set
+func rootPool(ca []byte, withSystem bool) (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	if withSystem {
+		var err error
+		if roots, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("unable to load the system roots: %w", err)
+		}
+	}
+	if len(ca) == 0 {
+		if withSystem {
+			return roots, nil
+		}
+		return nil, errors.New("cluster has no certificate authority")
+	}
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no valid certificate found in the certificate authority")
+	}
+	return roots, nil
+}
+
+// verifyChain retrieves the certificate presented by the server and
+// verifies it against the certificate authority of the cluster, or against
+// the system roots along with it when withSystem is set
+func verifyChain(ctx context.Context, cluster ClusterInfo, withSystem bool) error {
+	addr, err := serverAddress(cluster.Server)
+	if err != nil {
+		return err
+	}
+	ca, err := dataOrFile(cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err != nil {
+		return err
+	}
+	roots, err := rootPool(ca, withSystem)
+	if err != nil {
+		return err
+	}
+
+	host := cluster.TLSServerName
+	if host == "" {
+		host, _, _ = net.SplitHostPort(addr)
+	}
+	conn, err := dialTLS(ctx, cluster, addr, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		DNSName:       host,
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err = certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("server certificate %q is not trusted by the certificate authority: %w",
+			certs[0].Subject.CommonName, err)
+	}
+	return nil
+}
+
+// parseCertificates decodes all the PEM certificates of data
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
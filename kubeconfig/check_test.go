@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCA returns a certificate authority and its key
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// startTLSServer starts a server whose certificate is signed by the given
+// authority, valid for the given dns names or for 127.0.0.1 when there are
+// none
+func startTLSServer(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, names ...string) *httptest.Server {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if len(names) > 0 {
+		tmpl.DNSNames = names
+	} else {
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func pemData(cert *x509.Certificate) string {
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// serverConfig returns a config whose cluster is the given server, trusting
+// the certificate ca
+func serverConfig(server string, ca *x509.Certificate) string {
+	return `clusters:
+- name: local
+  cluster:
+    certificate-authority-data: ` + pemData(ca) + `
+    server: ` + server + `
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: admin
+current-context: local
+users:
+- name: admin
+  user:
+    token: secret
+`
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	dir := t.TempDir()
+
+	fname := writeFile(t, dir, "trusted", serverConfig(srv.URL, srv.Certificate()))
+	_, stderr, err := runMain(t, "-f", fname, "-c", "local", "-check-connectivity")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "connected to "+srv.URL) {
+		t.Errorf("the connection is not reported: %q", stderr)
+	}
+
+	other, _ := newTestCert(t, "other")
+	fname = writeFile(t, dir, "untrusted", serverConfig(srv.URL, other))
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "local", "-check-connectivity"); err == nil {
+		t.Errorf("the check with an unknown authority succeeded: %s", stderr)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	ca, caKey := newTestCA(t, "server-ca")
+	srv := startTLSServer(t, ca, caKey)
+	dir := t.TempDir()
+
+	fname := writeFile(t, dir, "trusted", serverConfig(srv.URL, ca))
+	if _, stderr, err := runMain(t, "-f", fname, "-c", "local", "-verify-chain"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+
+	other, _ := newTestCA(t, "other-ca")
+	fname = writeFile(t, dir, "untrusted", serverConfig(srv.URL, other))
+	_, stderr, err := runMain(t, "-f", fname, "-c", "local", "-verify-chain")
+	if err == nil {
+		t.Fatal("the chain signed by another authority is verified")
+	}
+	if !strings.Contains(stderr, `server certificate "server" is not trusted by the certificate authority`) {
+		t.Errorf("unexpected error: %q", stderr)
+	}
+}
+
+func TestCheckTLSServerName(t *testing.T) {
+	ca, caKey := newTestCA(t, "server-ca")
+	srv := startTLSServer(t, ca, caKey, "kubernetes.internal")
+	dir := t.TempDir()
+
+	config := serverConfig(srv.URL, ca)
+	fname := writeFile(t, dir, "dial-host", config)
+	if _, _, err := runMain(t, "-f", fname, "-c", "local", "-check-connectivity"); err == nil {
+		t.Error("the certificate is verified against the dial host")
+	}
+
+	config = strings.Replace(config, "    server: ", "    tls-server-name: kubernetes.internal\n    server: ", 1)
+	fname = writeFile(t, dir, "server-name", config)
+	for _, check := range []string{"-check-connectivity", "-verify-chain"} {
+		if _, stderr, err := runMain(t, "-f", fname, "-c", "local", check); err != nil {
+			t.Errorf("%s: %v: %s", check, err, stderr)
+		}
+	}
+}
+
+func TestRootPool(t *testing.T) {
+	ca, caKey := newTestCA(t, "cluster-ca")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	system, err := x509.SystemCertPool()
+	if err != nil {
+		t.Skipf("no system roots: %v", err)
+	}
+
+	roots, err := rootPool(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ca.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Errorf("the certificate authority is not in the pool: %v", err)
+	}
+	if _, err = ca.Verify(x509.VerifyOptions{Roots: system}); err == nil {
+		t.Error("the system pool is modified")
+	}
+	if roots, err = rootPool(nil, true); err != nil || !roots.Equal(system) {
+		t.Errorf("got %v without certificate authority", err)
+	}
+	if _, err = rootPool(nil, false); err == nil || err.Error() != "cluster has no certificate authority" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	srv := startTLSServer(t, ca, caKey)
+	fname := writeFile(t, t.TempDir(), "config", serverConfig(srv.URL, ca))
+	if _, stderr, err := runMain(t, "-f", fname, "-c", "local", "-verify-chain", "-merge-ca-into-system"); err != nil {
+		t.Errorf("%v: %s", err, stderr)
+	}
+}
+
+func TestVerifyCerts(t *testing.T) {
+	cert, _ := newTestCert(t, "alice")
+	_, other := newTestCert(t, "mallory")
+	der, err := x509.MarshalECPrivateKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	config := strings.Replace(testConfig, "    token: alice-token\n",
+		"    client-certificate-data: "+pemData(cert)+"\n    client-key-data: "+keyData+"\n", 1)
+	fname := writeFile(t, t.TempDir(), "config", config)
+
+	if _, stderr, err := runMain(t, "-f", fname, "-c", "*", "-verify-certs"); err == nil || !strings.Contains(stderr, `user "alice": tls: private key does not match public key`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	// the users without a client certificate are skipped
+	if _, stderr, err := runMain(t, "-f", fname, "-c", "prod", "-verify-certs"); err != nil {
+		t.Errorf("%v: %s", err, stderr)
+	}
+
+	cert, key := newTestCert(t, "alice")
+	if der, err = x509.MarshalECPrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	keyData = base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	config = strings.Replace(testConfig, "    token: alice-token\n",
+		"    client-certificate-data: "+pemData(cert)+"\n    client-key-data: "+keyData+"\n", 1)
+	if _, stderr, err := runMain(t, "-f", writeFile(t, t.TempDir(), "config", config), "-c", "dev", "-verify-certs"); err != nil {
+		t.Errorf("the matching pair is rejected: %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,44 @@
+//go:build clipboard
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// clipboardCommands returns the commands printing the clipboard, the
+// first one installed is used
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+	}
+	cmds := [][]string{
+		{"xclip", "-selection", "clipboard", "-out"},
+		{"xsel", "--clipboard", "--output"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append([][]string{{"wl-paste", "--no-newline"}}, cmds...)
+	}
+	return cmds
+}
+
+// readClipboard reads the content of the system clipboard with the tools
+// of the platform, so that no library is needed
+func readClipboard(ctx context.Context) ([]byte, error) {
+	for _, args := range clipboardCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		return cmd.Output()
+	}
+	return nil, errors.New("no clipboard tool found in PATH")
+}
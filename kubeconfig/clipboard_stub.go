@@ -0,0 +1,12 @@
+//go:build !clipboard
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func readClipboard(ctx context.Context) ([]byte, error) {
+	return nil, errors.New("clipboard support is not built in, rebuild with -tags clipboard")
+}
@@ -0,0 +1,17 @@
+//go:build !clipboard
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromClipboard(t *testing.T) {
+	if _, stderr, err := runMain(t, "list", "-from-clipboard"); err == nil || !strings.Contains(stderr, "rebuild with -tags clipboard") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err := runMain(t, "list", "-from-clipboard", "-f", "config"); err == nil || !strings.Contains(stderr, "-from-clipboard cannot be used with -f, -from-env or -fd") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
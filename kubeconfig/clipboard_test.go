@@ -0,0 +1,37 @@
+//go:build clipboard
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFromClipboard(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the stub clipboard tool is a shell script")
+	}
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", testConfig)
+	xclip := writeFile(t, dir, "xclip", "#!/bin/sh\ncat "+fname+"\n")
+	if err := os.Chmod(xclip, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	stdout, stderr, err := runMain(t, "list", "-from-clipboard")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "prod") {
+		t.Errorf("the config is not read:\n%s", stdout)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, stderr, err = runMain(t, "list", "-from-clipboard"); err == nil || !strings.Contains(stderr, "no clipboard tool found in PATH") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runSetCluster(args []string) error {
+	var (
+		server        string
+		tlsServerName string
+		proxyURL      string
+		caData        B64
+	)
+	fs := flag.NewFlagSet("set-cluster", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.StringVar(&server, "server", "", "url of the api server")
+	fs.StringVar(&tlsServerName, "tls-server-name", "", "server name to verify the server certificate against")
+	fs.StringVar(&proxyURL, "proxy-url", "", "url of the proxy to reach the api server through")
+	fs.Func("certificate-authority-data", "base64 encoded certificate authority to embed", inlinePEM(&caData))
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: set-cluster [flags] <cluster>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	name := fs.Arg(0)
+	if server == "" && tlsServerName == "" && proxyURL == "" && caData == nil {
+		return fmt.Errorf("nothing to set for cluster %q", name)
+	}
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+
+	cluster := cfg.FindCluster(name)
+	if cluster == nil {
+		_ = cfg.AddCluster(name, "", nil)
+		cluster = cfg.FindCluster(name)
+	}
+	if server != "" {
+		cluster.Cluster.Server = server
+	}
+	if tlsServerName != "" {
+		cluster.Cluster.TLSServerName = tlsServerName
+	}
+	if proxyURL != "" {
+		cluster.Cluster.ProxyURL = proxyURL
+	}
+	if caData != nil {
+		cluster.Cluster.CertificateAuthorityData = caData
+		cluster.Cluster.CertificateAuthority = ""
+	}
+
+	return edit.write(in, cfg)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestSetCluster(t *testing.T) {
+	ca, _ := newTestCA(t, "ca")
+	caData := pemData(ca)
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+
+	stdout, stderr, err := runMain(t, "set-cluster", "-f", fname, "-certificate-authority-data", caData, "-tls-server-name", "api.internal", "prod")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	// the new fields follow the ones of the source
+	want := `  - name: prod
+    cluster:
+      server: https://prod.example.com:6443
+      certificate-authority-data: ` + caData + `
+      tls-server-name: api.internal
+`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("%q not found in:\n%s", want, stdout)
+	}
+
+	stdout, stderr, err = runMain(t, "set-cluster", "-f", fname, "-server", "https://new.example.com", "new")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "  - name: new\n    cluster:\n      server: https://new.example.com\n") {
+		t.Errorf("the cluster is not added:\n%s", stdout)
+	}
+
+	notPEM := base64.StdEncoding.EncodeToString([]byte("not a certificate"))
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"dev"}, `nothing to set for cluster "dev"`},
+		{[]string{"-certificate-authority-data", "!!", "dev"}, "illegal base64 data"},
+		{[]string{"-certificate-authority-data", notPEM, "dev"}, "no PEM data found"},
+	} {
+		args := append([]string{"set-cluster", "-f", fname}, tt.args...)
+		if _, stderr, err = runMain(t, args...); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
+
+func TestSetCredentialsInline(t *testing.T) {
+	cert, _ := newTestCert(t, "alice")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	certData := base64.StdEncoding.EncodeToString(certPEM)
+	fname, dir := fileRefConfig(t)
+
+	stdout, stderr, err := runMain(t, "set-credentials", "-f", fname, "-client-certificate-data", certData, "alice")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "client-certificate-data: "+certData+"\n") || strings.Contains(stdout, "client.crt") {
+		t.Errorf("the certificate is not embedded:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "client-key: "+dir) {
+		t.Errorf("the key file is not kept:\n%s", stdout)
+	}
+
+	_, stderr, err = runMain(t, "set-credentials", "-f", fname, "-client-certificate-data", certData, "-client-certificate", "-", "alice")
+	if err == nil || !strings.Contains(stderr, "a credential cannot be given both as a file and as data") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
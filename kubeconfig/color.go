@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+)
+
+const (
+	colorDefault = "39"
+	colorGreen   = "32"
+	colorRed     = "31"
+)
+
+// colorOutput enables the colors of the tables, see useColor
+var colorOutput bool
+
+// useColor tells whether the tables are colored: -color forces it,
+// -no-color and NO_COLOR disable it, otherwise stdout has to be a terminal
+func useColor(force, disable bool) bool {
+	switch {
+	case disable:
+		return false
+	case force:
+		return true
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return !noColor && isTerminal(os.Stdout)
+}
+
+// paint wraps the table cell in the color. All the cells of a column have
+// to be painted, with colorDefault for the plain ones: the sequences all
+// have the same length so that the tabwriter alignment is kept
+func paint(s, color string) string {
+	if !colorOutput {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[" + colorDefault + "m"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// copyComments copies the comments of src onto the matching nodes of dst,
+// mapping values are matched by key and list items by name (or position),
+// the keys of the mappings are also ordered like in src when reorder is set
+func copyComments(dst, src *yaml.Node, reorder bool) {
+	if dst.Kind != src.Kind {
+		return
+	}
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+
+	switch dst.Kind {
+	case yaml.DocumentNode:
+		if len(dst.Content) > 0 && len(src.Content) > 0 {
+			copyComments(dst.Content[0], src.Content[0], reorder)
+		}
+	case yaml.MappingNode:
+		order := map[string]int{}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			order[src.Content[i].Value] = i
+		}
+		type pair struct{ key, value *yaml.Node }
+		pairs := make([]pair, 0, len(dst.Content)/2)
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			p := pair{dst.Content[i], dst.Content[i+1]}
+			if j, ok := order[p.key.Value]; ok {
+				copyComments(p.key, src.Content[j], reorder)
+				copyComments(p.value, src.Content[j+1], reorder)
+			}
+			pairs = append(pairs, p)
+		}
+		if !reorder {
+			break
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			oi, iok := order[pairs[i].key.Value]
+			oj, jok := order[pairs[j].key.Value]
+			if iok && jok {
+				return oi < oj
+			}
+			// the new keys go after the existing ones
+			return iok && !jok
+		})
+		for i, p := range pairs {
+			dst.Content[2*i], dst.Content[2*i+1] = p.key, p.value
+		}
+	case yaml.SequenceNode:
+		for i, item := range dst.Content {
+			if match := matchItem(src, item, i); match != nil {
+				copyComments(item, match, reorder)
+			}
+		}
+	}
+}
+
+// matchItem finds the item of the src list with the same name as item, or
+// at the same position when the items have no name
+func matchItem(src, item *yaml.Node, i int) *yaml.Node {
+	if name := nodeName(item); name != "" {
+		for _, n := range src.Content {
+			if nodeName(n) == name {
+				return n
+			}
+		}
+		return nil
+	}
+	if i < len(src.Content) {
+		return src.Content[i]
+	}
+	return nil
+}
+
+func nodeName(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "name" {
+			return n.Content[i+1].Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const commentedConfig = `# managed by hand, see the wiki
+apiVersion: v1
+kind: Config
+clusters:
+  # the local cluster
+  - name: dev
+    cluster:
+      server: https://dev.example.com # behind the vpn
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: alice
+current-context: dev
+users:
+  - name: alice
+    user:
+      token: alice-token
+`
+
+func TestEditKeepsComments(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", commentedConfig)
+	stdout, stderr, err := runMain(t, "make-insecure", "-f", fname, "-yes", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"# managed by hand, see the wiki\napiVersion: v1\nkind: Config\n",
+		"  # the local cluster\n  - name: dev\n",
+		"      server: https://dev.example.com # behind the vpn\n      insecure-skip-tls-verify: true\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+}
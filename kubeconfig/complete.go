@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func runComplete(args []string) error {
+	fs := flag.NewFlagSet("complete", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	for _, name := range completeContexts(cfg, fs.Arg(0)) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func completeContexts(cfg *Config, prefix string) []string {
+	var names []string
+	for _, name := range cfg.ContextNames() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
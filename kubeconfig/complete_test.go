@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	stdout, stderr, err := runMain(t, "complete", "-f", "testdata/rancher.yaml", "prod-n")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "prod-node1\nprod-node2\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	cfg := &Config{Contexts: []Context{{Name: "dev"}, {Name: "prod"}}}
+	if names := completeContexts(cfg, ""); len(names) != 2 {
+		t.Errorf("the empty prefix matches %v", names)
+	}
+	if names := completeContexts(cfg, "x"); len(names) != 0 {
+		t.Errorf("the prefix x matches %v", names)
+	}
+
+	// the command is left out of the usage
+	_, stderr, _ = runMain(t, "unknown")
+	if strings.Contains(stderr, "complete") {
+		t.Errorf("complete is listed in the usage:\n%s", stderr)
+	}
+}
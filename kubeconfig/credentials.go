@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func runSetCredentials(args []string) error {
+	var (
+		p12         string
+		p12Password string
+		certFile    string
+		keyFile     string
+		certData    B64
+		keyData     B64
+		tokenCmd    string
+	)
+	fs := flag.NewFlagSet("set-credentials", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.StringVar(&p12, "p12", "", "PKCS#12 file holding the client certificate and key")
+	fs.StringVar(&p12Password, "p12-password", "", "password of the PKCS#12 file")
+	fs.StringVar(&certFile, "client-certificate", "", "client certificate file to embed, - reads it from stdin")
+	fs.StringVar(&keyFile, "client-key", "", "client key file to embed, - reads it from stdin")
+	fs.Func("client-certificate-data", "base64 encoded client certificate to embed", inlinePEM(&certData))
+	fs.Func("client-key-data", "base64 encoded client key to embed", inlinePEM(&keyData))
+	fs.StringVar(&tokenCmd, "token-command", "", "shell command printing the token to embed, run once when setting the credentials")
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: set-credentials [flags] <user>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	name := fs.Arg(0)
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var cert, key []byte
+	inline := certData != nil || keyData != nil
+	switch {
+	case p12 != "" && (certFile != "" || keyFile != "" || inline || tokenCmd != ""):
+		return errors.New("-p12 cannot be used with the other credential flags")
+	case certFile != "" && certData != nil, keyFile != "" && keyData != nil:
+		return errors.New("a credential cannot be given both as a file and as data")
+	case p12 != "":
+		if cert, key, err = loadPKCS12(p12, p12Password); err != nil {
+			return fmt.Errorf("unable to load PKCS#12 file %q: %w", p12, err)
+		}
+	case certFile != "" || keyFile != "":
+		if cert, key, err = readCredentials(certFile, keyFile); err != nil {
+			return fmt.Errorf("unable to read credentials: %w", err)
+		}
+	case !inline && tokenCmd == "":
+		return fmt.Errorf("no credentials given for user %q", name)
+	}
+	var token string
+	if tokenCmd != "" {
+		if token, err = runTokenCommand(tokenCmd); err != nil {
+			return fmt.Errorf("unable to get token: %w", err)
+		}
+	}
+	if certData != nil {
+		cert = certData
+	}
+	if keyData != nil {
+		key = keyData
+	}
+
+	user := cfg.FindUser(name)
+	if user == nil {
+		_ = cfg.AddUser(name, UserInfo{})
+		user = cfg.FindUser(name)
+	}
+	if cert != nil {
+		user.User.ClientCertificateData = cert
+		user.User.ClientCertificate = ""
+	}
+	if key != nil {
+		user.User.ClientKeyData = key
+		user.User.ClientKey = ""
+	}
+	if token != "" {
+		user.User.Token = token
+		user.User.TokenFile = ""
+	}
+
+	return edit.write(in, cfg)
+}
+
+// runTokenCommand runs the command with the shell and returns its output
+// as the token, unlike an exec plugin it is only run once and the token is
+// embedded in the config
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := ioContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("command %q printed no token", command)
+	}
+	return token, nil
+}
+
+// readCredentials reads the client certificate and key files, "-" stands
+// for stdin: as it can only be read once, the certificate and the key are
+// told apart by their PEM type when both come from it
+func readCredentials(certFile, keyFile string) (cert, key []byte, err error) {
+	ctx, cancel := ioContext()
+	defer cancel()
+	if certFile == "-" && keyFile == "-" {
+		data, err := readAll(ctx, "stdin", os.Stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, key = splitPEM(data)
+		if cert == nil {
+			return nil, nil, errors.New("no certificate found in stdin")
+		}
+		if key == nil {
+			return nil, nil, errors.New("no private key found in stdin")
+		}
+		return cert, key, nil
+	}
+	if certFile != "" {
+		if cert, err = readPEM(ctx, certFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if keyFile != "" {
+		if key, err = readPEM(ctx, keyFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cert, key, nil
+}
+
+func readPEM(ctx context.Context, fname string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if fname == "-" {
+		fname = "stdin"
+		data, err = readAll(ctx, fname, os.Stdin)
+	} else {
+		data, err = readFile(ctx, fname)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", fname)
+	}
+	return data, nil
+}
+
+// inlinePEM returns a flag parser storing the base64 encoded PEM data
+// into dst
+func inlinePEM(dst *B64) func(string) error {
+	return func(s string) error {
+		data, err := decodeB64(s)
+		if err != nil {
+			return err
+		}
+		if block, _ := pem.Decode(data); block == nil {
+			return errors.New("no PEM data found")
+		}
+		*dst = data
+		return nil
+	}
+}
+
+// splitPEM separates the certificates from the private keys
+func splitPEM(data []byte) (cert, key []byte) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return cert, key
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert = append(cert, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			key = append(key, pem.EncodeToMemory(block)...)
+		}
+	}
+}
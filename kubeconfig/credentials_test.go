@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func writeTestP12(t *testing.T, dir, name string) string {
+	t.Helper()
+	cert, key := newTestCert(t, name)
+	data, err := pkcs12.LegacyRC2.WithRand(rand.Reader).Encode(key, cert, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, dir, name+".p12", string(data))
+}
+
+func TestSetCredentialsKeepsPaths(t *testing.T) {
+	fname, dir := fileRefConfig(t)
+	p12 := writeTestP12(t, dir, "bob")
+
+	stdout, stderr, err := runMain(t, "set-credentials", "-f", fname, "-p12", p12, "bob")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"certificate-authority: " + filepath.Join(dir, "ca.crt"),
+		"client-certificate: " + filepath.Join(dir, "client.crt"),
+		"client-key: " + filepath.Join(dir, "client.key"),
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "name: bob") || strings.Count(stdout, "client-certificate-data:") != 1 {
+		t.Errorf("the credentials of bob are not embedded:\n%s", stdout)
+	}
+
+	flattened, stderr, err := runMain(t, "set-credentials", "-f", fname, "-p12", p12, "-flatten", "bob")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Contains(flattened, dir) || strings.Count(flattened, "client-certificate-data:") != 2 {
+		t.Errorf("the files are not embedded:\n%s", flattened)
+	}
+}
+
+func TestSetCredentialsFromStdin(t *testing.T) {
+	cert, key := newTestCert(t, "alice")
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", testConfig)
+
+	// the key comes first, they are told apart by their type
+	stdout, stderr, err := runMainInput(t, string(keyPEM)+string(certPEM),
+		"set-credentials", "-f", fname, "-client-certificate", "-", "-client-key", "-", "alice")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"client-certificate-data: " + base64.StdEncoding.EncodeToString(certPEM),
+		"client-key-data: " + base64.StdEncoding.EncodeToString(keyPEM),
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+
+	keyFile := writeFile(t, dir, "client.key", string(keyPEM))
+	stdout, stderr, err = runMainInput(t, string(certPEM),
+		"set-credentials", "-f", fname, "-client-certificate", "-", "-client-key", keyFile, "bob")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Count(stdout, "client-key-data: "+base64.StdEncoding.EncodeToString(keyPEM)) != 1 {
+		t.Errorf("the key file is not embedded:\n%s", stdout)
+	}
+
+	for _, tt := range []struct {
+		stdin string
+		args  []string
+		want  string
+	}{
+		{string(certPEM), []string{"-client-certificate", "-", "-client-key", "-"}, "no private key found in stdin"},
+		{"garbage", []string{"-client-certificate", "-"}, "no PEM data found in stdin"},
+		{"", []string{"-client-certificate", "-", "-p12", "bob.p12"}, "-p12 cannot be used with the other credential flags"},
+	} {
+		args := append(append([]string{"set-credentials", "-f", fname}, tt.args...), "alice")
+		if _, stderr, err = runMainInput(t, tt.stdin, args...); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
+
+func TestSetCredentialsTokenCommand(t *testing.T) {
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", testConfig)
+	stub := writeFile(t, dir, "vault", "#!/bin/sh\necho \"  fresh-token-for-$1\"\n")
+	if err := os.Chmod(stub, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := runMain(t, "set-credentials", "-f", fname, "-token-command", stub+" bob", "bob")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("output", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user := cfg.FindUser("bob"); user == nil || user.User.Token != "fresh-token-for-bob" {
+		t.Errorf("the token is not embedded:\n%s", stdout)
+	}
+
+	for _, tt := range []struct {
+		command string
+		want    string
+	}{
+		{"echo denied >&2; exit 3", `command "echo denied >&2; exit 3" failed: exit status 3`},
+		{"true", `command "true" printed no token`},
+	} {
+		_, stderr, err := runMain(t, "set-credentials", "-f", fname, "-token-command", tt.command, "bob")
+		if err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%s: unexpected result %v: %s", tt.command, err, stderr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func runDeleteContext(args []string) error {
+	var prune bool
+	fs := flag.NewFlagSet("delete-context", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.BoolVar(&prune, "prune", false, "also remove the cluster and user of the context when no other context references them")
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: delete-context [flags] <context>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	for _, name := range fs.Args() {
+		if err = cfg.RemoveContext(name, prune); err != nil {
+			return err
+		}
+	}
+
+	return edit.write(in, cfg)
+}
+
+// addExcludeFlag registers the repeatable -exclude-context flag
+func addExcludeFlag(fs *flag.FlagSet) *[]string {
+	var names []string
+	fs.Func("exclude-context", "drop the context from the output along with the clusters and users only it uses (repeatable)", func(s string) error {
+		names = append(names, s)
+		return nil
+	})
+	return &names
+}
+
+// excludeContexts removes the contexts along with the clusters and users
+// only they referenced, the entries unused before are kept. The names
+// which are not contexts of cfg are ignored
+func excludeContexts(cfg *Config, names []string) {
+	for _, name := range names {
+		if cfg.FindContext(name) == nil {
+			log.Printf("warning: excluded context %q does not exist", name)
+			continue
+		}
+		_ = cfg.RemoveContext(name, true)
+	}
+}
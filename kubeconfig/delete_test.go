@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeleteContext(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "delete-context", "-f", fname, "-prune", "prod")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("output", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Contexts) != 1 || cfg.FindCluster("prod") != nil || cfg.FindUser("bob") != nil || cfg.CurrentContext != "dev" {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+
+	if stdout, stderr, err = runMain(t, "delete-context", "-f", fname, "dev"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Contains(stdout, "current-context") || !strings.Contains(stdout, "- name: alice") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+
+	if _, stderr, err = runMain(t, "delete-context", "-f", fname, "staging"); err == nil || !strings.Contains(stderr, `unable to find context "staging"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// expiryWarning is how long before the expiry of a certificate the doctor
+// starts warning about it
+const expiryWarning = 30 * 24 * time.Hour
+
+func runDoctor(args []string) error {
+	var (
+		context   string
+		checkConn bool
+	)
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	in := addInputFlags(fs)
+	addFormatFlag(fs)
+	fs.StringVar(&context, "c", "", "context name (defaults to the current context)")
+	fs.BoolVar(&checkConn, "check-connectivity", false, "also check that a tls connection can be established with the server")
+	fs.Parse(args)
+	if err := checkFormat(); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	if context == "" {
+		context = cfg.CurrentContext
+	}
+
+	results := diagnose(cfg, context, checkConn)
+	if outputFormat == "json" {
+		err = printJSON(os.Stdout, results)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tSTATUS\tMESSAGE")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Status, r.Message)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("context %q failed %d check(s)", context, failed)
+	}
+	return nil
+}
+
+// diagnose runs the checks of the context, the checks needing the
+// cluster and the user are skipped when the references are broken
+func diagnose(cfg *Config, context string, checkConn bool) []checkResult {
+	result := func(name string, err error) checkResult {
+		if err != nil {
+			return checkResult{name, checkFail, err.Error()}
+		}
+		return checkResult{Name: name, Status: checkPass}
+	}
+
+	single := *cfg
+	err := single.Minify(context)
+	if err == nil {
+		err = single.Validate()
+	}
+	results := []checkResult{result("references", err)}
+	if err != nil {
+		for _, name := range []string{"certificate-authority", "client-certificate", "expiry", "connectivity"} {
+			results = append(results, checkResult{name, checkSkip, "references are broken"})
+		}
+		return results
+	}
+	cluster := single.FindCluster(single.Contexts[0].Context.Cluster).Cluster
+	user := single.FindUser(single.Contexts[0].Context.User).User
+
+	var certs []*x509.Certificate
+	ca, err := dataOrFile(cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err == nil && len(ca) > 0 {
+		var parsed []*x509.Certificate
+		if parsed, err = parseCertificates(ca); err == nil {
+			certs = append(certs, parsed...)
+		}
+	}
+	switch {
+	case err == nil && len(ca) == 0:
+		results = append(results, checkResult{"certificate-authority", checkSkip, "cluster has no certificate authority"})
+	default:
+		results = append(results, result("certificate-authority", err))
+	}
+
+	cert, err := dataOrFile(user.ClientCertificateData, user.ClientCertificate)
+	var key []byte
+	if err == nil {
+		key, err = dataOrFile(user.ClientKeyData, user.ClientKey)
+	}
+	if err == nil && len(cert) > 0 {
+		if _, err = tls.X509KeyPair(cert, key); err == nil {
+			var parsed []*x509.Certificate
+			if parsed, err = parseCertificates(cert); err == nil {
+				certs = append(certs, parsed...)
+			}
+		}
+	}
+	switch {
+	case err == nil && len(cert) == 0:
+		results = append(results, checkResult{"client-certificate", checkSkip, "user has no client certificate"})
+	default:
+		results = append(results, result("client-certificate", err))
+	}
+
+	results = append(results, checkExpiry(certs, time.Now()))
+
+	if checkConn {
+		ctx, cancel := ioContext()
+		defer cancel()
+		results = append(results, result("connectivity", checkConnectivity(ctx, cluster, user)))
+	} else {
+		results = append(results, checkResult{"connectivity", checkSkip, "not requested"})
+	}
+	return results
+}
+
+func checkExpiry(certs []*x509.Certificate, now time.Time) checkResult {
+	if len(certs) == 0 {
+		return checkResult{"expiry", checkSkip, "no certificate to check"}
+	}
+	status := checkResult{Name: "expiry", Status: checkPass}
+	for _, c := range certs {
+		switch {
+		case now.After(c.NotAfter):
+			return checkResult{"expiry", checkFail, fmt.Sprintf("certificate %q expired on %s", c.Subject.CommonName, c.NotAfter.Format(time.RFC3339))}
+		case now.Add(expiryWarning).After(c.NotAfter):
+			status = checkResult{"expiry", checkWarn, fmt.Sprintf("certificate %q expires on %s", c.Subject.CommonName, c.NotAfter.Format(time.RFC3339))}
+		}
+	}
+	return status
+}
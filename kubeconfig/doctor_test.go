@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoctor(t *testing.T) {
+	ca, caKey := newTestCA(t, "ca")
+	srv := startTLSServer(t, ca, caKey)
+	fname := writeFile(t, t.TempDir(), "config", serverConfig(srv.URL, ca))
+
+	stdout, stderr, err := runMain(t, "doctor", "-f", fname, "-check-connectivity", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var results []checkResult
+	if err = json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"references:pass", "certificate-authority:pass", "client-certificate:skip", "expiry:warn", "connectivity:pass"}
+	if len(results) != len(want) {
+		t.Fatalf("got the results %+v", results)
+	}
+	for i, r := range results {
+		if got := r.Name + ":" + r.Status; got != want[i] {
+			t.Errorf("got %s, want %s (%s)", got, want[i], r.Message)
+		}
+	}
+
+	broken := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig, "user: bob", "user: nobody", 1))
+	stdout, stderr, err = runMain(t, "doctor", "-f", broken, "-c", "prod")
+	if err == nil {
+		t.Fatalf("expected an error:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, `context "prod" failed 1 check(s)`) {
+		t.Errorf("unexpected error %q", stderr)
+	}
+	if !strings.HasPrefix(stdout, "CHECK ") || !strings.Contains(stdout, "references ") || strings.Count(stdout, "references are broken") != 4 {
+		t.Errorf("unexpected report:\n%s", stdout)
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	cert, _ := newTestCert(t, "alice")
+	for _, tt := range []struct {
+		now  time.Time
+		want string
+	}{
+		{cert.NotAfter.Add(-2 * expiryWarning), checkPass},
+		{cert.NotAfter.Add(-time.Hour), checkWarn},
+		{cert.NotAfter.Add(time.Second), checkFail},
+	} {
+		if r := checkExpiry(nil, tt.now); r.Status != checkSkip {
+			t.Errorf("got %+v without certificates", r)
+		}
+		if r := checkExpiry([]*x509.Certificate{cert}, tt.now); r.Status != tt.want {
+			t.Errorf("%s: got %+v, want %s", tt.now, r, tt.want)
+		}
+	}
+}
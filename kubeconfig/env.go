@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// printEnv prints the shell exports describing the single context of the
+// minified config, the certificate authority is written to a temporary
+// file when caFile is set. The names of the variables start with prefix
+func printEnv(w io.Writer, cfg *Config, caFile bool, prefix string) error {
+	if prefix != "" && !envNameRegexp.MatchString(prefix) {
+		return fmt.Errorf("%q is not a valid variable name prefix", prefix)
+	}
+	if len(cfg.Contexts) != 1 {
+		return errors.New("the env format needs a single context")
+	}
+	ctx := cfg.Contexts[0]
+	cluster := cfg.FindCluster(ctx.Context.Cluster).Cluster
+	user := cfg.FindUser(ctx.Context.User).User
+
+	ca, err := dataOrFile(cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err != nil {
+		return err
+	}
+	cert, err := dataOrFile(user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := dataOrFile(user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return err
+	}
+
+	var vars [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, [2]string{prefix + name, value})
+		}
+	}
+	add("KUBE_CONTEXT", ctx.Name)
+	add("KUBE_SERVER", cluster.Server)
+	add("KUBE_NAMESPACE", ctx.Context.Namespace)
+	if cluster.InsecureSkipTLSVerify {
+		add("KUBE_INSECURE", strconv.FormatBool(true))
+	}
+	if caFile && len(ca) > 0 {
+		f, err := ioutil.TempFile("", "kube-ca-*.crt")
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(ca)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		add("KUBE_CA_FILE", f.Name())
+	} else {
+		add("KUBE_CA", string(ca))
+	}
+	add("KUBE_TOKEN", user.Token)
+	add("KUBE_CLIENT_CERTIFICATE", string(cert))
+	add("KUBE_CLIENT_KEY", string(key))
+
+	for _, v := range vars {
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", v[0], shellQuote(v[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
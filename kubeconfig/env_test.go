@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatEnv(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig, "token: alice-token", "token: it's-secret", 1))
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-format", "env", "-namespace-default", "team")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `export KUBE_CONTEXT='dev'
+export KUBE_SERVER='https://dev.example.com:6443'
+export KUBE_NAMESPACE='team'
+export KUBE_TOKEN='it'\''s-secret'
+`
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "*", "-format", "env"); err == nil || !strings.Contains(stderr, "the env format needs a single context") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-format", "xml"); err == nil || !strings.Contains(stderr, `unknown format "xml"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestFormatEnvCAFile(t *testing.T) {
+	cfg := &Config{
+		Clusters: []Cluster{{Name: "dev", Cluster: ClusterInfo{Server: "https://dev.example.com", CertificateAuthorityData: B64("ca")}}},
+		Contexts: []Context{{Name: "dev", Context: ContextInfo{Cluster: "dev", User: "alice"}}},
+		Users:    []User{{Name: "alice"}},
+	}
+	var buf bytes.Buffer
+	if err := printEnv(&buf, cfg, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "export KUBE_CA='ca'\n") {
+		t.Errorf("the certificate authority is not exported:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := printEnv(&buf, cfg, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "export KUBE_CA_FILE='"
+	i := strings.Index(buf.String(), prefix)
+	if i < 0 {
+		t.Fatalf("the certificate authority file is not exported:\n%s", buf.String())
+	}
+	path := buf.String()[i+len(prefix):]
+	path = path[:strings.IndexByte(path, '\'')]
+	defer os.Remove(path)
+	if data, err := os.ReadFile(path); err != nil || string(data) != "ca" {
+		t.Errorf("unexpected certificate authority file %q: %v", data, err)
+	}
+}
+
+func TestFormatEnvPrefix(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "prod", "-format", "env", "-env-prefix", "STAGING_")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `export STAGING_KUBE_CONTEXT='prod'
+export STAGING_KUBE_SERVER='https://prod.example.com:6443'
+export STAGING_KUBE_TOKEN='bob-token'
+`
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "prod", "-format", "env", "-env-prefix", "1-A"); err == nil || !strings.Contains(stderr, `"1-A" is not a valid variable name prefix`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
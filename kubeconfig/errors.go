@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+const (
+	exitFailure  = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// NotFoundError is returned when a named entry does not exist
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("unable to find %s %q", e.Kind, e.Name)
+}
+
+func exitCode(err error) int {
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return exitNotFound
+	}
+	return exitFailure
+}
+
+// fail reports the error and exits, as a json object on stderr when the
+// json output is selected
+func fail(err error) {
+	code := exitCode(err)
+	if outputFormat == "json" {
+		printJSON(os.Stderr, struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}{
+			Error: err.Error(),
+			Code:  code,
+		})
+	} else {
+		log.Print(err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestJSONError(t *testing.T) {
+	for _, args := range [][]string{
+		{"extract", "-f", "testdata/rancher.yaml", "-c", "missing", "-output", "json"},
+		{"list", "-f", "testdata/missing.yaml", "-output", "json"},
+		{"write-pem", "-f", "testdata/rancher.yaml", "-c", "missing", "-output", "json"},
+	} {
+		_, stderr, err := runMain(t, args...)
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("%v: expected an exit error, got %v", args, err)
+		}
+		var report struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}
+		if err = json.Unmarshal([]byte(stderr), &report); err != nil {
+			t.Fatalf("%v: the error is not json: %v\n%s", args, err, stderr)
+		}
+		if report.Code != exitErr.ExitCode() || report.Error == "" {
+			t.Errorf("%v: unexpected report %+v, exit status %d", args, report, exitErr.ExitCode())
+		}
+	}
+
+	_, stderr, err := runMain(t, "extract", "-f", "testdata/rancher.yaml", "-c", "missing", "-output", "json")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != exitNotFound {
+		t.Errorf("unexpected exit status %v", err)
+	}
+	if !strings.Contains(stderr, `"error": "unable to find context \"missing\""`) {
+		t.Errorf("unexpected error %s", stderr)
+	}
+
+	// the errors stay readable by default
+	_, stderr, _ = runMain(t, "extract", "-f", "testdata/rancher.yaml", "-c", "missing")
+	if strings.HasPrefix(stderr, "{") || !strings.Contains(stderr, `unable to find context "missing"`) {
+		t.Errorf("unexpected error %s", stderr)
+	}
+}
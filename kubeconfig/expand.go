@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} placeholders of the scalar values of the
+// tree with the environment variables, the keys and the exec settings of
+// the users are left as they are. The undefined variables are an error
+// unless allowMissing is set, they are then replaced with an empty string
+func expandEnv(node *yaml.Node, allowMissing bool) error {
+	missing := map[string]bool{}
+	expandNode(node, "", missing)
+	if len(missing) == 0 || allowMissing {
+		return nil
+	}
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("undefined variables: %s", strings.Join(names, ", "))
+}
+
+// expandNode walks the tree, path being the one of node with the list
+// indexes left out. The exec plugin settings are skipped, the plugin gets
+// its own environment when kubectl runs it
+func expandNode(node *yaml.Node, path string, missing map[string]bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value := placeholderRegexp.ReplaceAllStringFunc(node.Value, func(s string) string {
+			name := s[2 : len(s)-1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing[name] = true
+			}
+			return value
+		})
+		if value != node.Value {
+			// the tag was resolved on the placeholder, a plain ${VAR} is
+			// a string even when the value is a bool or a number
+			node.Value, node.Tag = value, ""
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			child := joinPath(path, node.Content[i].Value)
+			if child == "users.user.exec" {
+				continue
+			}
+			expandNode(node.Content[i+1], child, missing)
+		}
+	default:
+		for _, child := range node.Content {
+			expandNode(child, path, missing)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const templatedConfig = `apiVersion: v1
+kind: Config
+clusters:
+  - name: dev
+    cluster:
+      server: https://${DEV_HOST}:6443
+      insecure-skip-tls-verify: ${INSECURE}
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      namespace: "${NAMESPACE}"
+      user: ci
+  - name: eks
+    context:
+      cluster: dev
+      user: eks
+current-context: dev
+users:
+  - name: ci
+    user:
+      token: ${CI_TOKEN}
+  - name: eks
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1beta1
+        command: aws
+        env:
+          - name: AWS_PROFILE
+            value: ${AWS_PROFILE}
+`
+
+func TestExpandEnv(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", templatedConfig)
+	t.Setenv("DEV_HOST", "dev.example.com")
+	t.Setenv("INSECURE", "true")
+	t.Setenv("NAMESPACE", "2024")
+	t.Setenv("CI_TOKEN", "ci-token")
+	t.Setenv("AWS_PROFILE", "prod")
+
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "*", "-expand-env")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("output", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := cfg.FindCluster("dev").Cluster
+	if cluster.Server != "https://dev.example.com:6443" || !cluster.InsecureSkipTLSVerify {
+		t.Errorf("the cluster is not expanded: %+v", cluster)
+	}
+	if ns := cfg.FindContext("dev").Context.Namespace; ns != "2024" {
+		t.Errorf("got the namespace %q", ns)
+	}
+	if token := cfg.FindUser("ci").User.Token; token != "ci-token" {
+		t.Errorf("got the token %q", token)
+	}
+	// the exec plugin expands its own environment
+	if !strings.Contains(stdout, "value: ${AWS_PROFILE}") {
+		t.Errorf("the exec settings are expanded:\n%s", stdout)
+	}
+
+	missing := writeFile(t, t.TempDir(), "config", strings.NewReplacer("${DEV_HOST}", "${KUBECONFIG_TEST_HOST}", "${CI_TOKEN}", "${KUBECONFIG_TEST_TOKEN}").Replace(templatedConfig))
+	if _, stderr, err = runMain(t, "-f", missing, "-c", "*", "-expand-env"); err == nil || !strings.Contains(stderr, missing+": undefined variables: KUBECONFIG_TEST_HOST, KUBECONFIG_TEST_TOKEN") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	stdout, stderr, err = runMain(t, "-f", missing, "-c", "*", "-expand-env", "-allow-missing")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "server: https://:6443\n") || strings.Contains(stdout, "token:") {
+		t.Errorf("the undefined variables are not replaced with an empty string:\n%s", stdout)
+	}
+
+	// without -expand-env the placeholders are kept
+	plain := writeFile(t, t.TempDir(), "config", strings.Replace(templatedConfig, "      insecure-skip-tls-verify: ${INSECURE}\n", "", 1))
+	if stdout, stderr, err = runMain(t, "-f", plain, "-c", "dev"); err != nil || !strings.Contains(stdout, "token: ${CI_TOKEN}") {
+		t.Errorf("unexpected result %v: %s\n%s", err, stderr, stdout)
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func runExtract(args []string) error {
+	var (
+		context     string
+		contextFile string
+		out         extractOutput
+		pick        bool
+		outFile     string
+		relativeOut bool
+		byCluster   string
+		contextEnv  string
+		printCA     string
+
+		namespaceDefault string
+		insecure         bool
+		onlyCluster      bool
+		replaceHost      string
+		outputName       string
+		redactServers    bool
+		keepClusters     bool
+		keepUsers        bool
+		trimCurrent      bool
+		audit            bool
+		replaceToken     string
+		replaceTokenFile string
+		force            bool
+		anonymous        bool
+
+		checkConn   bool
+		checkExp    bool
+		verifyCerts bool
+		verify      bool
+		systemRoots bool
+	)
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.StringVar(&context, "c", "", "context name, or a glob pattern matching several contexts")
+	fs.StringVar(&contextFile, "context-file", "", "file listing the context names to extract, one per line")
+	fs.StringVar(&contextEnv, "context-env", "KUBECONFIG_CONTEXT", "environment variable holding the context name when -c is not given")
+	fs.StringVar(&byCluster, "by-cluster", "", "extract the context referencing the given cluster")
+	fs.StringVar(&printCA, "print-ca", "", "print the certificate authority of the given cluster as PEM instead of the config")
+	fs.BoolVar(&pick, "interactive", false, "choose the context from a list when -c is not given")
+	fs.StringVar(&out.format, "format", "yaml", "output format (yaml, env for shell exports, or json with -only-auth and -fields)")
+	fs.BoolVar(&out.envCAFile, "env-ca-file", false, "write the certificate authority to a temporary file and export its path (with -format env)")
+	fs.StringVar(&out.envPrefix, "env-prefix", "", "prefix of the exported variable names (with -format env, e.g. STAGING_)")
+	fs.BoolVar(&out.onlyAuth, "only-auth", false, "print only the credentials of the user")
+	fs.BoolVar(&out.decodeAuth, "decode", false, "print the certificate data of -only-auth as plain PEM")
+	fs.BoolVar(&out.hash, "hash", false, "print the sha256 of the normalized config instead of the config, to detect the changes")
+	fs.BoolVar(&out.summary, "summary", false, "print a one line json summary of the context instead of the config")
+	fs.Func("fields", "print only the comma separated fields of the context, its cluster and user (e.g. server,token,namespace)", parseFields(&out.fields))
+	fs.StringVar(&out.tmpl, "template", "", "render the output with the given go template instead of yaml")
+	fs.StringVar(&outFile, "o", "", "write the output to the file instead of stdout")
+	fs.BoolVar(&relativeOut, "relative-out", false, "rewrite the relative file references to be relative to the -o file (with -no-embed or -embed-ca-only)")
+	exclude := addExcludeFlag(fs)
+	fs.StringVar(&namespaceDefault, "namespace-default", "", "namespace to set on the context if it has none")
+	fs.BoolVar(&insecure, "insecure", false, "skip the tls verification of the cluster and drop its certificate authority")
+	fs.BoolVar(&onlyCluster, "only-current-cluster", false, "warn about the servers of the clusters dropped from the output")
+	fs.BoolVar(&keepClusters, "keep-clusters", false, "keep all the clusters instead of only the referenced ones")
+	fs.BoolVar(&keepUsers, "keep-users", false, "keep all the users instead of only the referenced ones")
+	fs.StringVar(&outputName, "output-context-name", "", "name of the context in the output (defaults to the source name)")
+	fs.BoolVar(&trimCurrent, "trim-current", false, "leave the current-context out so merging the output keeps the active context of the target")
+	fs.BoolVar(&audit, "audit", false, "report on stderr the clusters, contexts and users removed from the output")
+	fs.StringVar(&replaceHost, "replace-host", "", "replace the host:port of the cluster server, keeping the scheme and path")
+	fs.StringVar(&replaceToken, "replace-token", "", "replace the bearer token of the user")
+	fs.StringVar(&replaceTokenFile, "replace-token-file", "", "replace the bearer token of the user with the content of the file")
+	fs.BoolVar(&force, "force", false, "set the token with -replace-token even if the user does not use one")
+	fs.BoolVar(&anonymous, "anonymous", false, "drop the credentials, the contexts use an empty anonymous user")
+	fs.BoolVar(&redactServers, "redact-servers", false, "replace the server urls with placeholders")
+	fs.BoolVar(&checkConn, "check-connectivity", false, "check that a tls connection can be established with the server")
+	fs.BoolVar(&checkExp, "check-expiry", false, "warn when the client certificate of the user is expired or expires soon")
+	fs.BoolVar(&verifyCerts, "verify-certs", false, "check that the client certificates of the users match their keys")
+	fs.BoolVar(&verify, "verify-chain", false, "verify the server certificate against the certificate authority")
+	fs.BoolVar(&systemRoots, "merge-ca-into-system", false, "verify the chain against the system roots along with the certificate authority (with -verify-chain)")
+	addOutputFlags(fs, true)
+	addErrorFormatFlag(fs)
+	fs.Parse(args)
+	if err := checkFormat(); err != nil {
+		return err
+	}
+	switch {
+	case out.format == "json" && !out.onlyAuth && out.fields == nil:
+		return errors.New("-format json needs -only-auth or -fields")
+	case out.format == "env" && (out.onlyAuth || out.fields != nil):
+		return errors.New("-format env cannot be used with -only-auth or -fields")
+	case out.format != "yaml" && out.format != "env" && out.format != "json":
+		return fmt.Errorf("unknown format %q", out.format)
+	}
+	if anonymous && (keepUsers || out.onlyAuth || replaceToken != "" || replaceTokenFile != "") {
+		return errors.New("-anonymous cannot be used with the user flags")
+	}
+	if relativeOut && (outFile == "" || !noEmbed && !embedCAOnly) {
+		return errors.New("-relative-out needs -o and -no-embed or -embed-ca-only")
+	}
+
+	if replaceTokenFile != "" {
+		if replaceToken != "" {
+			return errors.New("-replace-token cannot be used with -replace-token-file")
+		}
+		data, err := ioutil.ReadFile(replaceTokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read token: %w", err)
+		}
+		if replaceToken = strings.TrimSpace(string(data)); replaceToken == "" {
+			return fmt.Errorf("token file %s is empty", replaceTokenFile)
+		}
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	// the audit also reports the entries removed along with the excluded
+	// contexts
+	loadedClusters, loadedContexts, loadedUsers := cfg.Clusters, cfg.Contexts, cfg.Users
+	excludeContexts(cfg, *exclude)
+
+	if printCA != "" {
+		return writeClusterCA(os.Stdout, cfg, printCA)
+	}
+
+	if byCluster != "" {
+		if context != "" || contextFile != "" {
+			return errors.New("-by-cluster cannot be used with -c or -context-file")
+		}
+		if context, err = contextByCluster(cfg, byCluster); err != nil {
+			return err
+		}
+	}
+
+	// -c wins over the environment, which wins over the current context
+	if context == "" && contextFile == "" && contextEnv != "" {
+		context = os.Getenv(contextEnv)
+	}
+	if context == "" && pick {
+		if !isTerminal(os.Stdin) {
+			return errors.New("unable to choose a context: stdin is not a terminal")
+		}
+		if context, err = pickContext(os.Stdin, os.Stderr, cfg); err != nil {
+			return fmt.Errorf("unable to choose a context: %w", err)
+		}
+	}
+
+	if context == "" && contextFile == "" {
+		context = cfg.CurrentContext
+	}
+
+	var patterns []string
+	if contextFile != "" {
+		if patterns, err = readNames(contextFile); err != nil {
+			return fmt.Errorf("unable to read context names: %w", err)
+		}
+	}
+	if context != "" || len(patterns) == 0 {
+		patterns = append(patterns, context)
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := matchContexts(cfg, pattern)
+		if err != nil {
+			return err
+		}
+		for _, name := range matches {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	allClusters, allUsers := cfg.Clusters, cfg.Users
+	if len(names) == 1 {
+		err = cfg.Minify(names[0])
+	} else {
+		err = cfg.Select(names...)
+	}
+	if err != nil {
+		return err
+	}
+	if keepClusters {
+		cfg.Clusters = allClusters
+	}
+	if keepUsers {
+		cfg.Users = allUsers
+	}
+	if audit {
+		var dropped []string
+		for _, cluster := range loadedClusters {
+			if cfg.FindCluster(cluster.Name) == nil {
+				dropped = append(dropped, cluster.Name)
+			}
+		}
+		logDropped("clusters", dropped)
+		dropped = nil
+		for _, ctx := range loadedContexts {
+			if cfg.FindContext(ctx.Name) == nil {
+				dropped = append(dropped, ctx.Name)
+			}
+		}
+		logDropped("contexts", dropped)
+		dropped = nil
+		for _, user := range loadedUsers {
+			if cfg.FindUser(user.Name) == nil {
+				dropped = append(dropped, user.Name)
+			}
+		}
+		logDropped("users", dropped)
+	}
+	if onlyCluster {
+		for _, cluster := range loadedClusters {
+			if cfg.FindCluster(cluster.Name) == nil {
+				log.Printf("warning: dropping cluster %q (%s)", cluster.Name, cluster.Cluster.Server)
+			}
+		}
+	}
+
+	if outputName != "" {
+		if len(cfg.Contexts) > 1 {
+			return errors.New("-output-context-name needs a single context")
+		}
+		cfg.Contexts[0].Name = outputName
+		cfg.CurrentContext = outputName
+	}
+	if trimCurrent {
+		cfg.CurrentContext = ""
+	}
+
+	for i := range cfg.Contexts {
+		if cfg.Contexts[i].Context.Namespace == "" {
+			cfg.Contexts[i].Context.Namespace = namespaceDefault
+		}
+	}
+
+	// only the clusters of the contexts are changed, even with -keep-clusters
+	changed := map[string]bool{}
+	for _, ctx := range cfg.Contexts {
+		if changed[ctx.Context.Cluster] {
+			continue
+		}
+		changed[ctx.Context.Cluster] = true
+		cluster := cfg.FindCluster(ctx.Context.Cluster)
+		if replaceHost != "" {
+			if cluster.Cluster.Server, err = withHost(cluster.Cluster.Server, replaceHost); err != nil {
+				return fmt.Errorf("unable to replace the server host: %w", err)
+			}
+		}
+		if insecure {
+			setInsecure(cluster)
+		}
+	}
+
+	if replaceToken != "" {
+		replaced := map[string]bool{}
+		for _, ctx := range cfg.Contexts {
+			if replaced[ctx.Context.User] {
+				continue
+			}
+			replaced[ctx.Context.User] = true
+			user := cfg.FindUser(ctx.Context.User)
+			if user.User.Token == "" && user.User.TokenFile == "" && !force {
+				return fmt.Errorf("user %q does not use a token, use -force to set one", user.Name)
+			}
+			user.User.Token = replaceToken
+			user.User.TokenFile = ""
+		}
+	}
+
+	if anonymous {
+		cfg.Users = []User{{Name: "anonymous"}}
+		for i := range cfg.Contexts {
+			cfg.Contexts[i].Context.User = "anonymous"
+		}
+	}
+
+	if checkExp {
+		for _, user := range cfg.Users {
+			cert, err := dataOrFile(user.User.ClientCertificateData, user.User.ClientCertificate)
+			if err != nil || len(cert) == 0 {
+				continue
+			}
+			certs, err := parseCertificates(cert)
+			if err != nil {
+				log.Printf("warning: unable to parse the client certificate of user %q: %v", user.Name, err)
+				continue
+			}
+			if r := checkExpiry(certs, time.Now()); r.Status == checkWarn || r.Status == checkFail {
+				log.Printf("warning: user %q: %s", user.Name, r.Message)
+			}
+		}
+	}
+
+	if verifyCerts {
+		var errs []error
+		for _, user := range cfg.Users {
+			if err = checkKeyPair(user.User); err != nil {
+				errs = append(errs, fmt.Errorf("user %q: %w", user.Name, err))
+			}
+		}
+		if err = errors.Join(errs...); err != nil {
+			return fmt.Errorf("unable to verify the client certificates: %w", err)
+		}
+	}
+
+	for _, ctx := range cfg.Contexts {
+		cluster := cfg.FindCluster(ctx.Context.Cluster).Cluster
+		user := cfg.FindUser(ctx.Context.User).User
+		if checkConn {
+			checkCtx, cancel := ioContext()
+			err = checkConnectivity(checkCtx, cluster, user)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("unable to connect to %s: %w", cluster.Server, err)
+			}
+			log.Printf("connected to %s", cluster.Server)
+		}
+		if verify {
+			checkCtx, cancel := ioContext()
+			err = verifyChain(checkCtx, cluster, systemRoots)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("unable to verify %s: %w", cluster.Server, err)
+			}
+			log.Printf("server certificate of %s is trusted", cluster.Server)
+		}
+	}
+
+	if redactServers {
+		cfg.RedactServers()
+	}
+
+	if relativeOut {
+		if err = cfg.RelocatePaths(configDir, filepath.Dir(outFile)); err != nil {
+			return fmt.Errorf("unable to relocate the file references: %w", err)
+		}
+		// the references are relative to the -o file now
+		configDir = filepath.Dir(outFile)
+	}
+
+	if outFile == "" {
+		return out.write(os.Stdout, cfg)
+	}
+	var buf bytes.Buffer
+	if err = out.write(&buf, cfg); err != nil {
+		return err
+	}
+	if err = writeFileAtomic(outFile, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("unable to write output: %w", err)
+	}
+	return nil
+}
+
+// extractOutput holds the flags choosing how extract prints the config
+type extractOutput struct {
+	tmpl       string
+	format     string
+	envCAFile  bool
+	envPrefix  string
+	onlyAuth   bool
+	decodeAuth bool
+	summary    bool
+	hash       bool
+	fields     []string
+}
+
+func (o *extractOutput) write(w io.Writer, cfg *Config) error {
+	if o.tmpl != "" {
+		t, err := template.New("output").Parse(o.tmpl)
+		if err != nil {
+			return fmt.Errorf("unable to parse template: %w", err)
+		}
+		if err = t.Execute(w, cfg); err != nil {
+			return fmt.Errorf("unable to render template: %w", err)
+		}
+		return nil
+	}
+
+	if o.hash {
+		sum, err := cfg.Hash()
+		if err != nil {
+			return fmt.Errorf("unable to hash config: %w", err)
+		}
+		_, err = fmt.Fprintln(w, sum)
+		return err
+	}
+
+	if o.summary {
+		return printSummary(w, cfg)
+	}
+
+	if o.fields != nil {
+		return printFields(w, cfg, o.fields, o.format)
+	}
+
+	if o.onlyAuth {
+		return printAuth(w, cfg, o.format, o.decodeAuth)
+	}
+
+	if o.format == "env" {
+		return printEnv(w, cfg, o.envCAFile, o.envPrefix)
+	}
+
+	if err := printConfig(w, cfg); err != nil {
+		return fmt.Errorf("unable to marshal config: %w", err)
+	}
+	return nil
+}
+
+func logDropped(kind string, names []string) {
+	if len(names) == 0 {
+		log.Printf("audit: no %s dropped", kind)
+		return
+	}
+	log.Printf("audit: dropped %d %s: %s", len(names), kind, strings.Join(names, ", "))
+}
+
+// withHost returns the server url with its host:port replaced by host
+func withHost(server, host string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("server %q has no host", server)
+	}
+	if strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	u.Host = host
+	return u.String(), nil
+}
+
+// contextByCluster returns the name of the context referencing the
+// cluster, several contexts referencing it is an error
+func contextByCluster(cfg *Config, cluster string) (string, error) {
+	if cfg.FindCluster(cluster) == nil {
+		return "", &NotFoundError{"cluster", cluster}
+	}
+	var names []string
+	for _, ctx := range cfg.Contexts {
+		if ctx.Context.Cluster == cluster {
+			names = append(names, ctx.Name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no context references cluster %q", cluster)
+	case 1:
+		return names[0], nil
+	}
+	return "", fmt.Errorf("cluster %q is referenced by several contexts, choose one with -c: %s", cluster, strings.Join(names, ", "))
+}
+
+// matchContexts returns the names of the contexts matching the pattern, or
+// the pattern itself when it is a plain name
+func matchContexts(cfg *Config, pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[\\") {
+		return []string{pattern}, nil
+	}
+	var names []string
+	for _, ctx := range cfg.Contexts {
+		ok, err := filepath.Match(pattern, ctx.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context pattern %q: %w", pattern, err)
+		}
+		if ok {
+			names = append(names, ctx.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, &NotFoundError{"context", pattern}
+	}
+	return names, nil
+}
+
+// readNames reads the names listed one per line in the file, ignoring the
+// blank lines and the # comments
+func readNames(fname string) ([]string, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "prod",
+		"-template", `{{range .Clusters}}{{.Cluster.Server}}{{end}} {{range .Users}}{{.User.Token}}{{end}}`)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "https://prod.example.com:6443 bob-token"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+}
+
+func TestNamespaceDefault(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig, "    user: bob\n", "    user: bob\n    namespace: team\n", 1))
+	for context, want := range map[string]string{"dev": "default", "prod": "team"} {
+		stdout, stderr, err := runMain(t, "-f", fname, "-c", context, "-namespace-default", "default",
+			"-template", "{{range .Contexts}}{{.Context.Namespace}}{{end}}")
+		if err != nil {
+			t.Fatalf("%v: %s", err, stderr)
+		}
+		if stdout != want {
+			t.Errorf("context %s: got namespace %q, want %q", context, stdout, want)
+		}
+	}
+}
+
+func TestInsecure(t *testing.T) {
+	config := strings.Replace(testConfig, "    server: https://dev.example.com:6443\n",
+		"    certificate-authority-data: Y2E=\n    server: https://dev.example.com:6443\n", 1)
+	fname := writeFile(t, t.TempDir(), "config", config)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-insecure")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Contains(stdout, "certificate-authority") {
+		t.Errorf("the certificate authority is kept:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "insecure-skip-tls-verify: true") {
+		t.Errorf("the tls verification is not disabled:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "warning") {
+		t.Errorf("no warning printed: %q", stderr)
+	}
+	if data, _ := os.ReadFile(fname); string(data) != config {
+		t.Error("the source file is modified")
+	}
+}
+
+func TestRancherConfig(t *testing.T) {
+	const ca = "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUJ2VENDQVdPZ0F3SUJBZ0lCQURBS0JnZ3Foa2pPUFFRREFqQkdNUnd3R2dZRFZRUUtFeE5rZVc1aGJXbGoKYkdsemRHVnVaWEl0YjNKbk1TWXdKQVlEVlFRRERCMWtlVzVoYldsamJHbHpkR1Z1WlhJdFkyRkFNVGN3TURBdwotLS0tLUVORCBDRVJUSUZJQ0FURS0tLS0tCg=="
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"certificate-authority-data: " + ca,
+		"server: https://10.0.0.11:6443",
+		"token: kubeconfig-user-q8w9e:t5x6lf2bz4qh8wr9mkl6cn2pvs7dj3tg",
+		"current-context: prod-node1",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+	for _, other := range []string{"rancher.example.com", "10.0.0.12"} {
+		if strings.Contains(stdout, other) {
+			t.Errorf("the cluster %s is kept:\n%s", other, stdout)
+		}
+	}
+
+	// the extracted config extracts the same again
+	fname := writeFile(t, t.TempDir(), "config", stdout)
+	again, stderr, err := runMain(t, "-f", fname, "-c", "prod-node1")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if again != stdout {
+		t.Errorf("the round trip changed the config:\n%s\nwant:\n%s", again, stdout)
+	}
+}
+
+func TestOnlyCurrentClusterWarning(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-only-current-cluster")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		`dropping cluster "prod" (https://rancher.example.com/k8s/clusters/c-x7k2p)`,
+		`dropping cluster "prod-node2" (https://10.0.0.12:6443)`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, `"prod-node1"`) {
+		t.Errorf("the kept cluster is reported:\n%s", stderr)
+	}
+	if strings.Contains(stdout, "10.0.0.12") {
+		t.Errorf("the dropped cluster is kept:\n%s", stdout)
+	}
+}
+
+func TestReplaceHost(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod", "-replace-host", "rancher.internal:8443")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "server: https://rancher.internal:8443/k8s/clusters/c-x7k2p"; !strings.Contains(stdout, want) {
+		t.Errorf("%q not found in:\n%s", want, stdout)
+	}
+
+	for _, host := range []string{"evil.example.com/path", "user@host"} {
+		if _, err = withHost("https://rancher.example.com/k8s", host); err == nil {
+			t.Errorf("the host %q is accepted", host)
+		}
+	}
+	if _, err = withHost("not a url", "host"); err == nil {
+		t.Error("the server without a host is accepted")
+	}
+}
+
+func TestOutputContextName(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-output-context-name", "ci")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{"  - name: ci\n    context:\n      cluster: prod-node1\n", "current-context: ci\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "name: prod-node1\n    context") {
+		t.Errorf("the source name is kept:\n%s", stdout)
+	}
+}
+
+func TestGlobContexts(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node*",
+		"-template", "{{range .Contexts}}{{.Name}} {{end}}{{range .Clusters}}{{.Name}} {{end}}{{len .Users}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "prod-node1 prod-node2 prod-node1 prod-node2 1"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	if _, stderr, err = runMain(t, "-f", "testdata/rancher.yaml", "-c", "dev-*"); err == nil || !strings.Contains(stderr, `unable to find context "dev-*"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestRedactServers(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node*", "-redact-servers")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if got := strings.Count(stdout, "server: https://REDACTED\n"); got != 2 {
+		t.Errorf("%d servers are redacted in:\n%s", got, stdout)
+	}
+	if strings.Contains(stdout, "10.0.0.1") {
+		t.Errorf("a server is kept:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "certificate-authority-data: ") {
+		t.Errorf("the certificate authority is dropped:\n%s", stdout)
+	}
+}
+
+func TestKeepEntries(t *testing.T) {
+	tmpl := "{{range .Clusters}}{{.Name}} {{end}}{{range .Users}}{{.Name}} {{end}}"
+	for _, tt := range []struct {
+		flags []string
+		want  string
+	}{
+		{nil, "dev alice "},
+		{[]string{"-keep-clusters"}, "dev prod alice "},
+		{[]string{"-keep-users"}, "dev alice bob "},
+		{[]string{"-keep-clusters", "-keep-users"}, "dev prod alice bob "},
+	} {
+		fname := writeFile(t, t.TempDir(), "config", testConfig)
+		args := append([]string{"-f", fname, "-c", "dev", "-template", tmpl}, tt.flags...)
+		stdout, stderr, err := runMain(t, args...)
+		if err != nil {
+			t.Fatalf("%v: %v: %s", tt.flags, err, stderr)
+		}
+		if stdout != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.flags, stdout, tt.want)
+		}
+	}
+
+	stdout, stderr, err := runMain(t, "-f", writeFile(t, t.TempDir(), "config", testConfig), "-c", "dev", "-keep-clusters", "-replace-host", "dev.internal:443")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "server: https://prod.example.com:6443\n") {
+		t.Errorf("the kept cluster is changed:\n%s", stdout)
+	}
+}
+
+func TestContextFile(t *testing.T) {
+	dir := t.TempDir()
+	names := writeFile(t, dir, "names", "# the nodes\nprod-node2\n\n  prod-node*  # again\nprod-node1\n")
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-context-file", names,
+		"-template", "{{range .Contexts}}{{.Name}} {{end}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "prod-node2 prod-node1 "; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	missing := writeFile(t, dir, "missing", "prod\nstaging\n")
+	if _, stderr, err = runMain(t, "-f", "testdata/rancher.yaml", "-context-file", missing); err == nil || !strings.Contains(stderr, `unable to find context "staging"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestTrimCurrent(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node*", "-trim-current")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Contains(stdout, "current-context") {
+		t.Errorf("current-context is kept:\n%s", stdout)
+	}
+	if strings.Count(stdout, "  - name: prod-node") != 4 {
+		t.Errorf("the contexts are not extracted:\n%s", stdout)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	_, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-audit")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"audit: dropped 2 clusters: prod, prod-node2\n",
+		"audit: dropped 2 contexts: prod, prod-node2\n",
+		"audit: no users dropped\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+
+	_, stderr, err = runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-audit", "-keep-clusters")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "audit: no clusters dropped\n") {
+		t.Errorf("the kept clusters are reported:\n%s", stderr)
+	}
+}
+
+func TestByCluster(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-by-cluster", "prod-node2", "-template", "{{.CurrentContext}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "prod-node2" {
+		t.Errorf("got the context %q", stdout)
+	}
+
+	fname := writeFile(t, t.TempDir(), "config", splitConfig)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-by-cluster", "x"}, `cluster "x" is referenced by several contexts, choose one with -c: a, team/b`},
+		{[]string{"-by-cluster", "y"}, `unable to find cluster "y"`},
+		{[]string{"-by-cluster", "x", "-c", "a"}, "-by-cluster cannot be used with -c or -context-file"},
+	} {
+		if _, stderr, err = runMain(t, append([]string{"-f", fname}, tt.args...)...); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
+
+func TestReplaceToken(t *testing.T) {
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "prod", "-replace-token", "fresh-token")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "token: fresh-token\n") || strings.Contains(stdout, "bob-token") {
+		t.Errorf("the token is not replaced:\n%s", stdout)
+	}
+	if data, err := os.ReadFile(fname); err != nil || string(data) != testConfig {
+		t.Errorf("the source file is changed: %v", err)
+	}
+
+	tokenFile := writeFile(t, dir, "token", "file-token\n")
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "prod", "-replace-token-file", tokenFile)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "token: file-token\n") {
+		t.Errorf("the token of the file is not set:\n%s", stdout)
+	}
+
+	certConfig, _ := fileRefConfig(t)
+	if _, stderr, err = runMain(t, "-f", certConfig, "-c", "dev", "-no-embed", "-replace-token", "fresh-token"); err == nil || !strings.Contains(stderr, `user "alice" does not use a token, use -force to set one`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	stdout, stderr, err = runMain(t, "-f", certConfig, "-c", "dev", "-no-embed", "-replace-token", "fresh-token", "-force")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "token: fresh-token\n") {
+		t.Errorf("the token is not forced:\n%s", stdout)
+	}
+}
+
+func TestContextPrecedence(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	extracted := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, err := runMain(t, append([]string{"-f", fname, "-template", "{{.CurrentContext}}"}, args...)...)
+		if err != nil {
+			t.Fatalf("%v: %v: %s", args, err, stderr)
+		}
+		return stdout
+	}
+
+	t.Setenv("KUBECONFIG_CONTEXT", "")
+	if got := extracted(); got != "dev" {
+		t.Errorf("got %q without -c, want the current context", got)
+	}
+	t.Setenv("KUBECONFIG_CONTEXT", "prod")
+	if got := extracted(); got != "prod" {
+		t.Errorf("got %q, want the context of the environment", got)
+	}
+	if got := extracted("-c", "dev"); got != "dev" {
+		t.Errorf("got %q, want the context of -c", got)
+	}
+	t.Setenv("CI_CONTEXT", "dev")
+	if got := extracted("-context-env", "CI_CONTEXT"); got != "dev" {
+		t.Errorf("got %q, want the context of -context-env", got)
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-anonymous")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"server: https://10.0.0.11:6443\n",
+		"certificate-authority-data: ",
+		"      user: anonymous\n",
+		"users:\n  - name: anonymous\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "token") || strings.Contains(stdout, "kubeconfig-user") {
+		t.Errorf("the credentials are kept:\n%s", stdout)
+	}
+
+	if _, stderr, err = runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod", "-anonymous", "-keep-users"); err == nil || !strings.Contains(stderr, "-anonymous cannot be used with the user flags") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestCheckExpiryWarning(t *testing.T) {
+	cert, err := filepath.Abs("testdata/expired.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig, "    token: alice-token\n", "    client-certificate: "+cert+"\n", 1))
+	_, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-check-expiry")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, `warning: user "alice": certificate "expired-user" expired on 2021-01-01T00:00:00Z`) {
+		t.Errorf("the expired certificate is not reported: %s", stderr)
+	}
+
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "dev"); err != nil || strings.Contains(stderr, "expired") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	// only the users of the output are checked
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "prod", "-check-expiry"); err != nil || strings.Contains(stderr, "expired") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestExcludeContext(t *testing.T) {
+	config := testConfig + `- name: legacy
+  user:
+    token: legacy-token
+`
+	fname := writeFile(t, t.TempDir(), "config", config)
+
+	stdout, stderr, err := runMain(t, "merge", "-exclude-context", "prod", "-exclude-context", "staging", fname)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("merged", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.FindContext("prod") != nil || cfg.FindCluster("prod") != nil || cfg.FindUser("bob") != nil {
+		t.Errorf("the excluded context and its entries are kept:\n%s", stdout)
+	}
+	if cfg.FindContext("dev") == nil || cfg.FindUser("legacy") == nil {
+		t.Errorf("the entries unrelated to the excluded context are dropped:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, `warning: excluded context "staging" does not exist`) {
+		t.Errorf("the unknown context is not reported: %s", stderr)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "*", "-exclude-context", "prod", "-audit", "-template", "{{range .Contexts}}{{.Name}} {{end}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "dev " {
+		t.Errorf("got the contexts %q", stdout)
+	}
+	for _, want := range []string{
+		"audit: dropped 1 clusters: prod\n",
+		"audit: dropped 1 contexts: prod\n",
+		"audit: dropped 2 users: bob, legacy\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+}
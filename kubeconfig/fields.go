@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseFields returns a flag parser storing the comma separated field
+// names into dst, the names are the yaml ones of the clusters, contexts
+// and users, and name for the context name
+func parseFields(dst *[]string) func(string) error {
+	return func(s string) error {
+		known := strippableFields()
+		known["name"] = true
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !known[name] {
+				return fmt.Errorf("unknown field %q", name)
+			}
+			*dst = append(*dst, name)
+		}
+		return nil
+	}
+}
+
+// printFields prints the fields of the single context of the minified
+// config and of its cluster and user as a flat map, in the order they are
+// asked for. The certificate data is printed as plain PEM
+func printFields(w io.Writer, cfg *Config, fields []string, format string) error {
+	if len(cfg.Contexts) != 1 {
+		return errors.New("-fields needs a single context")
+	}
+	ctx := cfg.Contexts[0]
+	values := map[string]*yaml.Node{
+		"name": {Kind: yaml.ScalarNode, Tag: "!!str", Value: ctx.Name},
+	}
+	for _, v := range []interface{}{
+		cfg.FindCluster(ctx.Context.Cluster).Cluster,
+		ctx.Context,
+		cfg.FindUser(ctx.Context.User).User,
+	} {
+		var node yaml.Node
+		if err := node.Encode(v); err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if strings.HasSuffix(key, "-data") {
+				data, err := decodeB64(value.Value)
+				if err != nil {
+					return err
+				}
+				value.Value = string(data)
+				value.Style = yaml.LiteralStyle
+			}
+			values[key] = value
+		}
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range fields {
+		if value, ok := values[name]; ok {
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+		}
+	}
+	if format == "json" {
+		var m map[string]interface{}
+		if err := root.Decode(&m); err != nil {
+			return err
+		}
+		if m == nil {
+			m = map[string]interface{}{}
+		}
+		return printJSON(w, m)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(indent)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(strings.Replace(testConfig,
+		"    server: https://dev.example.com:6443\n",
+		"    server: https://dev.example.com:6443\n    certificate-authority-data: "+base64.StdEncoding.EncodeToString([]byte("ca data\n"))+"\n", 1),
+		"    user: alice\n", "    user: alice\n    namespace: web\n", 1))
+
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-fields", "server,token,namespace")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "server: https://dev.example.com:6443\ntoken: alice-token\nnamespace: web\n"; stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-fields", "name,certificate-authority-data,username", "-format", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "{\n  \"certificate-authority-data\": \"ca data\\n\",\n  \"name\": \"dev\"\n}\n"; stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-fields", "server,colour"}, `unknown field "colour"`},
+		{[]string{"-fields", "server", "-format", "env"}, "-format env cannot be used with -only-auth or -fields"},
+		{[]string{"-format", "json"}, "-format json needs -only-auth or -fields"},
+	} {
+		_, stderr, err := runMain(t, append([]string{"-f", fname, "-c", "dev"}, tt.args...)...)
+		if err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// editFlags are the flags of the commands modifying a config
+type editFlags struct {
+	inPlace bool
+	backup  bool
+}
+
+func addEditFlags(fs *flag.FlagSet) *editFlags {
+	e := &editFlags{}
+	fs.BoolVar(&e.inPlace, "in-place", false, "write the result back to the input file instead of stdout")
+	fs.BoolVar(&e.backup, "backup", false, "keep a copy of the input file with a .bak suffix (with -in-place)")
+	addOutputFlags(fs, false)
+	addErrorFormatFlag(fs)
+	return e
+}
+
+func (e *editFlags) check(in *input) error {
+	if err := checkFormat(); err != nil {
+		return err
+	}
+	if e.inPlace && (in.fname == "" || in.fname == "-" || in.fromEnv != "") {
+		return errors.New("-in-place needs an input file")
+	}
+	if e.inPlace && in.secret {
+		return errors.New("-in-place cannot rewrite a Secret manifest")
+	}
+	if e.inPlace && filepath.Ext(in.fname) == ".zip" {
+		return errors.New("-in-place cannot rewrite a zip archive")
+	}
+	return nil
+}
+
+// write prints the edited config, or replaces the input file with it
+func (e *editFlags) write(in *input, cfg *Config) error {
+	if !e.inPlace {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			return fmt.Errorf("unable to marshal config: %w", err)
+		}
+		return nil
+	}
+
+	fname, err := filepath.EvalSymlinks(in.fname)
+	if err != nil {
+		return err
+	}
+	if e.backup {
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		if err = writeFileAtomic(fname+".bak", data, 0600); err != nil {
+			return fmt.Errorf("unable to write backup: %w", err)
+		}
+	}
+	if err = WriteConfig(fname, cfg, 0600); err != nil {
+		return fmt.Errorf("unable to write config: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file next to filename and
+// renames it over filename, so that readers never see a partial file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func copyFixture(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, dir, name, string(data))
+}
+
+func TestInPlaceAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	fname := copyFixture(t, dir, "rancher.yaml")
+	if err := os.Chmod(fname, 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := runMain(t, "make-insecure", "-f", fname, "-in-place", "-yes", "prod-node1")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("the config is printed:\n%s", stdout)
+	}
+	after, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file is replaced by a rename rather than rewritten
+	if os.SameFile(before, after) {
+		t.Error("the input file is written in place")
+	}
+	if mode := after.Mode().Perm(); mode != 0600 {
+		t.Errorf("the mode is %v, want 0600", mode)
+	}
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "insecure-skip-tls-verify: true") {
+		t.Errorf("the edit is not written:\n%s", data)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
+
+func TestInPlaceBackup(t *testing.T) {
+	dir := t.TempDir()
+	fname := copyFixture(t, dir, "rancher.yaml")
+	original, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the target of a symlink is the file edited
+	link := filepath.Join(dir, "config")
+	if err = os.Symlink(fname, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stderr, err := runMain(t, "make-insecure", "-f", link, "-in-place", "-backup", "-yes", "prod-node1"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	backup, err := os.ReadFile(fname + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("the backup differs from the original:\n%s", backup)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink is replaced: %v", err)
+	}
+	if data, _ := os.ReadFile(fname); string(data) == string(original) {
+		t.Error("the target of the symlink is not edited")
+	}
+}
+
+func TestInPlaceNeedsAFile(t *testing.T) {
+	t.Setenv("TEST_KUBECONFIG", "")
+	for _, args := range [][]string{
+		{"make-insecure", "-f", "-", "-in-place", "-yes", "dev"},
+		{"make-insecure", "-from-env", "TEST_KUBECONFIG", "-in-place", "-yes", "dev"},
+	} {
+		_, stderr, err := runMain(t, args...)
+		if err == nil || !strings.Contains(stderr, "-in-place needs an input file") {
+			t.Errorf("%v: unexpected result %v: %s", args, err, stderr)
+		}
+	}
+}
+
+func TestInPlaceKeepsAllFields(t *testing.T) {
+	fname := copyFixture(t, t.TempDir(), "minikube.yaml")
+	if _, stderr, err := runMain(t, "make-insecure", "-f", fname, "-in-place", "-yes", "minikube"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"      extensions:\n        - extension:\n            last-update: Tue, 13 Oct 2026 09:12:44 UTC\n",
+		"          name: cluster_info\n",
+		"          name: context_info\n",
+		"      disable-compression: true\n",
+		"      as: admin\n",
+		"      as-groups:\n        - system:masters\n",
+		"      as-uid: \"1000\"\n",
+		"      as-user-extra:\n        reason:\n          - debugging\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%q not found in:\n%s", want, data)
+		}
+	}
+}
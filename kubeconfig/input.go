@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// yamlError prefixes the yaml error messages with the file name, in the
+// usual "file:line: message" form when the line is known
+func yamlError(name string, err error) error {
+	format := func(msg string) string {
+		if m := yamlLineRegexp.FindStringSubmatch(msg); m != nil {
+			return fmt.Sprintf("%s:%s: %s", name, m[1], msg[len(m[0]):])
+		}
+		return fmt.Sprintf("%s: %s", name, strings.TrimPrefix(msg, "yaml: "))
+	}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs := make([]string, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			msgs = append(msgs, format(msg))
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	return errors.New(format(err.Error()))
+}
+
+func parseConfig(name string, data []byte) (*Config, error) {
+	doc, err := parseDoc(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDoc(name, doc)
+}
+
+func parseDoc(name string, data []byte) (*yaml.Node, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("input config is empty")
+	}
+	// the comments of configs edited on windows are printed back followed
+	// by a blank line otherwise
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlError(name, err)
+	}
+	return &doc, nil
+}
+
+func decodeDoc(name string, doc *yaml.Node) (*Config, error) {
+	var cfg Config
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, yamlError(name, err)
+	}
+	cfg.doc = doc
+	return &cfg, nil
+}
+
+// parseJSONConfig parses a config encoded in json with the json decoder,
+// the comments cannot be kept
+func parseJSONConfig(name string, data []byte) (*Config, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("input config is empty")
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &cfg, nil
+}
+
+func loadConfig(fname string) (*Config, error) {
+	ctx, cancel := ioContext()
+	defer cancel()
+	data, err := readFile(ctx, fname)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(fname, data)
+}
+
+type input struct {
+	fname   string
+	fromEnv string
+	fd      int
+	expand  bool
+	missing bool
+	paste   bool
+	format  string
+	strict  bool
+	fix     bool
+	dedup   bool
+	schema  bool
+	rekey   bool
+	slugify bool
+	norm    bool
+
+	secret    bool
+	secretKey string
+}
+
+func addInputFlags(fs *flag.FlagSet) *input {
+	in := &input{}
+	fs.StringVar(&in.fname, "f", "", "input kube config file name (~/.kube/*.conf)")
+	fs.StringVar(&in.fromEnv, "from-env", "", "read the base64 encoded config from the given environment variable")
+	fs.IntVar(&in.fd, "fd", -1, "read the config from the given inherited file descriptor")
+	fs.BoolVar(&in.paste, "from-clipboard", false, "read the config from the system clipboard (needs a build with -tags clipboard)")
+	fs.StringVar(&in.format, "input-format", "yaml", "format of the input config (yaml or json)")
+	fs.BoolVar(&in.expand, "expand-env", false, "replace the ${VAR} placeholders of the values with the environment variables")
+	fs.BoolVar(&in.missing, "allow-missing", false, "replace the undefined variables of -expand-env with an empty string instead of failing")
+	fs.BoolVar(&in.strict, "strict", false, "fail instead of warning when the config is incomplete")
+	fs.BoolVar(&in.fix, "fix", false, "default the missing apiVersion and kind to v1 and Config")
+	fs.BoolVar(&in.secret, "secret", false, "the input is a Secret manifest holding the config")
+	fs.StringVar(&in.secretKey, "secret-key", "kubeconfig", "key of the config in the Secret manifest")
+	fs.DurationVar(&ioTimeout, "timeout", ioTimeout, "timeout of the reads of the inputs and of the connectivity checks")
+	fs.Int64Var(&maxInputSize, "max-size", maxInputSize, "maximum size in bytes of the inputs, 0 for no limit")
+	fs.BoolVar(&in.dedup, "dedup-contexts", false, "remove the contexts defined more than once, keeping the first")
+	fs.BoolVar(&in.slugify, "canonicalize-names", false, "lowercase and hyphenate the names of the clusters, contexts and users")
+	fs.BoolVar(&in.norm, "normalize", false, "sort the entries by name and default the apiVersion and kind")
+	fs.BoolVar(&in.rekey, "rekey", false, "re-read the files referenced by the entries which also embed their data")
+	fs.BoolVar(&in.schema, "schema-check", false, "fail on the unknown fields and the values of the wrong kind")
+	return in
+}
+
+func (in *input) load() (*Config, error) {
+	cfg, err := in.read()
+	if err != nil {
+		return nil, err
+	}
+	if in.schema {
+		if err = cfg.checkSchema(in.name()); err != nil {
+			return nil, err
+		}
+	}
+	if err = cfg.checkHeader(); err != nil {
+		switch {
+		case in.fix:
+			if cfg.ApiVersion == "" {
+				cfg.ApiVersion = "v1"
+			}
+			if cfg.Kind == "" {
+				cfg.Kind = "Config"
+			}
+		case in.strict:
+			return nil, err
+		default:
+			log.Printf("warning: %v", err)
+		}
+	}
+	if err = cfg.checkAuth(); err != nil {
+		if in.strict {
+			return nil, err
+		}
+		log.Printf("warning: %v", err)
+	}
+	if in.rekey {
+		rekeyed, err := cfg.Rekey()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range rekeyed {
+			log.Printf("rekeyed %s", name)
+		}
+	}
+	if in.dedup {
+		for _, name := range cfg.DedupContexts() {
+			log.Printf("removed duplicate context %q", name)
+		}
+	}
+	if in.slugify {
+		for _, r := range cfg.CanonicalizeNames() {
+			log.Printf("renamed %s %q to %q", r.Kind, r.From, r.To)
+		}
+	}
+	if in.norm {
+		cfg.Normalize()
+	}
+	return cfg, nil
+}
+
+// name returns the name of the input used in the messages
+func (in *input) name() string {
+	switch {
+	case in.paste:
+		return "clipboard"
+	case in.fd >= 0:
+		return fmt.Sprintf("fd %d", in.fd)
+	case in.fromEnv != "":
+		return "$" + in.fromEnv
+	}
+	return in.fname
+}
+
+func (in *input) read() (*Config, error) {
+	var (
+		name = in.name()
+		data []byte
+		err  error
+	)
+	switch {
+	case in.paste && (in.fname != "" || in.fromEnv != "" || in.fd >= 0):
+		return nil, errors.New("-from-clipboard cannot be used with -f, -from-env or -fd")
+	case in.paste:
+		ctx, cancel := ioContext()
+		defer cancel()
+		if data, err = readClipboard(ctx); err != nil {
+			return nil, fmt.Errorf("unable to read the clipboard: %w", err)
+		}
+	case in.fd >= 0 && (in.fname != "" || in.fromEnv != ""):
+		return nil, errors.New("-fd cannot be used with -f or -from-env")
+	case in.fd >= 0:
+		f := os.NewFile(uintptr(in.fd), name)
+		if _, err = f.Stat(); err != nil {
+			return nil, fmt.Errorf("%s is not open: %w", name, err)
+		}
+		defer f.Close()
+		ctx, cancel := ioContext()
+		defer cancel()
+		if data, err = readAll(ctx, name, f); err != nil {
+			return nil, err
+		}
+	case in.fromEnv != "":
+		s, ok := os.LookupEnv(in.fromEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", in.fromEnv)
+		}
+		if data, err = decodeB64(s); err != nil {
+			return nil, fmt.Errorf("environment variable %s: %w", in.fromEnv, err)
+		}
+	default:
+		configDir = filepath.Dir(in.fname)
+		ctx, cancel := ioContext()
+		defer cancel()
+		if data, err = readFile(ctx, in.fname); err != nil {
+			return nil, err
+		}
+		if filepath.Ext(in.fname) == ".zip" {
+			if in.expand {
+				return nil, errors.New("-expand-env cannot be used with a zip archive")
+			}
+			return parseZip(in.fname, data)
+		}
+	}
+	if in.secret {
+		if data, err = secretData(name, data, in.secretKey); err != nil {
+			return nil, err
+		}
+	}
+	if in.expand {
+		// json is yaml, the placeholders are replaced in the parsed tree
+		// so that the values cannot break the structure
+		doc, err := parseDoc(name, data)
+		if err != nil {
+			return nil, err
+		}
+		if err = expandEnv(doc, in.missing); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cfg, err := decodeDoc(name, doc)
+		if err != nil {
+			return nil, err
+		}
+		if in.format == "json" {
+			cfg.doc = nil
+		}
+		return cfg, nil
+	}
+	switch in.format {
+	case "yaml":
+		return parseConfig(name, data)
+	case "json":
+		return parseJSONConfig(name, data)
+	}
+	return nil, fmt.Errorf("unknown input format %q", in.format)
+}
+
+// checkHeader reports a missing apiVersion or kind, which kubectl requires
+func (c *Config) checkHeader() error {
+	var missing []string
+	if c.ApiVersion == "" {
+		missing = append(missing, "apiVersion")
+	}
+	if c.Kind == "" {
+		missing = append(missing, "kind")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("config has no %s", strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// authMethods returns the authentication methods set on the user
+func authMethods(user UserInfo) []string {
+	var methods []string
+	if len(user.ClientCertificateData) > 0 || user.ClientCertificate != "" {
+		methods = append(methods, "client-certificate")
+	}
+	if user.Token != "" || user.TokenFile != "" {
+		methods = append(methods, "token")
+	}
+	if user.Username != "" || user.Password != "" {
+		methods = append(methods, "basic")
+	}
+	if user.AuthProvider != nil {
+		methods = append(methods, "auth-provider")
+	}
+	if user.Exec != nil {
+		methods = append(methods, "exec")
+	}
+	return methods
+}
+
+// checkAuth reports the users with several authentication methods, kubectl
+// picks one of them but it is usually a mistake
+func (c *Config) checkAuth() error {
+	var errs []error
+	for _, user := range c.Users {
+		if methods := authMethods(user.User); len(methods) > 1 {
+			errs = append(errs, fmt.Errorf("user %q has conflicting authentication methods: %s", user.Name, strings.Join(methods, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEmptyInput(t *testing.T) {
+	for _, content := range []string{"", " \n\t\n"} {
+		fname := writeFile(t, t.TempDir(), "config", content)
+		_, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+		if err == nil {
+			t.Fatalf("%q: expected an error", content)
+		}
+		if !strings.Contains(stderr, "input config is empty") {
+			t.Errorf("%q: unexpected error %q", content, stderr)
+		}
+	}
+}
+
+func TestParseErrorNamesTheFile(t *testing.T) {
+	_, stderr, err := runMain(t, "-f", "testdata/tabs.yaml", "-c", "dev")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(stderr, "testdata/tabs.yaml:5: ") {
+		t.Errorf("the file name and line are not reported: %q", stderr)
+	}
+}
+
+func TestParseTypeErrors(t *testing.T) {
+	_, err := parseConfig("config", []byte("clusters:\n- name: [dev]\ncontexts: {}\n"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "config:2: ") || !strings.HasPrefix(lines[1], "config:3: ") {
+		t.Errorf("unexpected error:\n%v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TEST_KUBECONFIG", base64.StdEncoding.EncodeToString([]byte(testConfig)))
+	stdout, stderr, err := runMain(t, "list", "-from-env", "TEST_KUBECONFIG")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "prod") {
+		t.Errorf("the config is not read:\n%s", stdout)
+	}
+
+	t.Setenv("TEST_KUBECONFIG", "not base64!")
+	if _, stderr, err = runMain(t, "list", "-from-env", "TEST_KUBECONFIG"); err == nil || !strings.Contains(stderr, "TEST_KUBECONFIG") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err = runMain(t, "list", "-from-env", "TEST_KUBECONFIG_UNSET"); err == nil || !strings.Contains(stderr, "is not set") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestMissingHeader(t *testing.T) {
+	fragment := testConfig[strings.Index(testConfig, "clusters:"):]
+	fname := writeFile(t, t.TempDir(), "config", fragment)
+
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "warning: config has no apiVersion and kind") {
+		t.Errorf("no warning in %q", stderr)
+	}
+	if strings.Contains(stdout, "apiVersion") {
+		t.Errorf("the header is added without -fix:\n%s", stdout)
+	}
+
+	if _, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-strict"); err == nil || !strings.Contains(stderr, "config has no apiVersion and kind") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-strict", "-fix")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.HasPrefix(stdout, "apiVersion: v1\n") || !strings.Contains(stdout, "kind: Config\n") {
+		t.Errorf("the header is not fixed:\n%s", stdout)
+	}
+	if strings.Contains(stderr, "warning") {
+		t.Errorf("unexpected warning %q", stderr)
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	stdout, stderr, err := runMain(t, "make-insecure", "-f", "testdata/crlf.yaml", "-yes", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Contains(stdout, "\r") {
+		t.Errorf("the output keeps the carriage returns:\n%q", stdout)
+	}
+	if !strings.HasPrefix(stdout, "# edited on windows\napiVersion: v1\n") {
+		t.Errorf("the comment is not printed back as it is:\n%s", stdout)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", "testdata/crlf.yaml", "-template", "{{range .Clusters}}{{.Cluster.CertificateAuthorityData}}{{end}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "ca data" {
+		t.Errorf("got the certificate authority %q", stdout)
+	}
+}
+
+func TestExecUser(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/eks.yaml", "-strict")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `      exec:
+        apiVersion: client.authentication.k8s.io/v1beta1
+        command: aws
+        args:
+          - --region
+          - eu-west-1
+          - eks
+          - get-token
+          - --cluster-name
+          - prod
+        env:
+          - name: AWS_PROFILE
+            value: prod
+        interactiveMode: IfAvailable
+`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("%q not found in:\n%s", want, stdout)
+	}
+}
+
+func TestConflictingAuthMethods(t *testing.T) {
+	data, err := os.ReadFile("testdata/eks.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(string(data), "    exec:\n", "    token: leftover\n    exec:\n", 1))
+	const want = `user "arn:aws:eks:eu-west-1:123456789012:cluster/prod" has conflicting authentication methods: token, exec`
+
+	_, stderr, err := runMain(t, "-f", fname)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "warning: "+want) {
+		t.Errorf("no warning in %q", stderr)
+	}
+	if _, stderr, err = runMain(t, "-f", fname, "-strict"); err == nil || !strings.Contains(stderr, want) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestReadFromFd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(testConfig)
+		w.Close()
+	}()
+	// the read end of the pipe is the fd 3 of the command
+	cmd := exec.Command(os.Args[0], "list", "-fd", "3")
+	cmd.Env = append(os.Environ(), "KUBECONFIG_TEST_MAIN=1")
+	cmd.ExtraFiles = []*os.File{r}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	r.Close()
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr.String())
+	}
+	if !strings.Contains(string(stdout), "prod") {
+		t.Errorf("the config is not read:\n%s", stdout)
+	}
+
+	if _, stderr, err := runMain(t, "list", "-fd", "1000"); err == nil || !strings.Contains(stderr, "fd 1000 is not open") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err := runMain(t, "list", "-fd", "3", "-f", "config"); err == nil || !strings.Contains(stderr, "-fd cannot be used with -f or -from-env") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestJSONInput(t *testing.T) {
+	const config = `{
+  "apiVersion": "v1",
+  "kind": "Config",
+  "clusters": [{"name": "dev", "cluster": {"server": "https://dev.example.com", "certificate-authority-data": "Y2EgZGF0YQ=="}}],
+  "contexts": [{"name": "dev", "context": {"cluster": "dev", "user": "alice", "namespace": "web"}}],
+  "current-context": "dev",
+  "users": [{"name": "alice", "user": {"token": "alice-token", "as-groups": ["admins"]}}]
+}`
+	fname := writeFile(t, t.TempDir(), "config.json", config)
+	stdout, stderr, err := runMain(t, "-f", fname, "-input-format", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("output", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := cfg.FindUser("alice")
+	if string(cfg.FindCluster("dev").Cluster.CertificateAuthorityData) != "ca data" ||
+		cfg.FindContext("dev").Context.Namespace != "web" ||
+		user == nil || user.User.Token != "alice-token" || len(user.User.ImpersonateGroups) != 1 {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+
+	if _, stderr, err = runMain(t, "-f", fname, "-input-format", "toml"); err == nil || !strings.Contains(stderr, `unknown input format "toml"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestZipInput(t *testing.T) {
+	stdout, stderr, err := runMain(t, "list", "-f", "testdata/bundle.zip", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, `"name": "dev"`) || !strings.Contains(stdout, `"name": "prod"`) {
+		t.Errorf("the contexts of both configs are not listed:\n%s", stdout)
+	}
+
+	stdout, stderr, err = runMain(t, "-f", "testdata/bundle.zip", "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "server: https://dev.example.com:6443") {
+		t.Errorf("the first config does not win:\n%s", stdout)
+	}
+
+	if _, stderr, err = runMain(t, "rename", "-f", "testdata/bundle.zip", "-in-place", "context", "dev", "staging"); err == nil || !strings.Contains(stderr, "-in-place cannot rewrite a zip archive") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// setInsecure disables the tls verification of the cluster and drops its
+// certificate authority
+func setInsecure(cluster *Cluster) {
+	log.Printf("warning: tls verification is disabled for cluster %q", cluster.Name)
+	cluster.Cluster.InsecureSkipTLSVerify = true
+	cluster.Cluster.CertificateAuthorityData = nil
+	cluster.Cluster.CertificateAuthority = ""
+}
+
+func runMakeInsecure(args []string) error {
+	var yes bool
+	fs := flag.NewFlagSet("make-insecure", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.BoolVar(&yes, "yes", false, "confirm that the tls verification of the cluster should be disabled")
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: make-insecure [flags] <cluster>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	name := fs.Arg(0)
+	if !yes {
+		return errors.New("refusing to disable the tls verification without -yes")
+	}
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	cluster := cfg.FindCluster(name)
+	if cluster == nil {
+		return &NotFoundError{"cluster", name}
+	}
+	setInsecure(cluster)
+
+	return edit.write(in, cfg)
+}
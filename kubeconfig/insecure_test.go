@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeInsecure(t *testing.T) {
+	if _, stderr, err := runMain(t, "make-insecure", "-f", "testdata/rancher.yaml", "prod-node1"); err == nil || !strings.Contains(stderr, "-yes") {
+		t.Errorf("the command runs without -yes: %v: %s", err, stderr)
+	}
+
+	stdout, stderr, err := runMain(t, "make-insecure", "-f", "testdata/rancher.yaml", "-yes", "prod-node1")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, `warning: tls verification is disabled for cluster "prod-node1"`) {
+		t.Errorf("no warning printed: %q", stderr)
+	}
+	want := `  - name: prod-node1
+    cluster:
+      server: https://10.0.0.11:6443
+      insecure-skip-tls-verify: true
+`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("the cluster is not made insecure:\n%s", stdout)
+	}
+	// the other clusters keep their certificate authority
+	if strings.Count(stdout, "certificate-authority-data:") != 1 {
+		t.Errorf("unexpected certificate authorities:\n%s", stdout)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// ioTimeout bounds the reads of the inputs and the connectivity checks, set
+// by -timeout, so that a hung fifo, stdin, network filesystem or server does
+// not block automation
+var ioTimeout = 30 * time.Second
+
+// maxInputSize caps the size of the inputs, set by -max-size, so that
+// loading a huge file by mistake fails early, 0 disables the cap
+var maxInputSize int64 = 10 << 20
+
+func ioContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), ioTimeout)
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// readAll reads r until EOF or until ctx is done, the blocked read itself
+// cannot be interrupted and is left behind
+func readAll(ctx context.Context, name string, r io.Reader) ([]byte, error) {
+	ch := make(chan readResult, 1)
+	go func() {
+		data, err := readLimited(name, r)
+		ch <- readResult{data, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("unable to read %s: %w", name, ctx.Err())
+	}
+}
+
+func readFile(ctx context.Context, fname string) ([]byte, error) {
+	ch := make(chan readResult, 1)
+	go func() {
+		f, err := os.Open(fname)
+		if err != nil {
+			ch <- readResult{nil, err}
+			return
+		}
+		defer f.Close()
+		data, err := readLimited(fname, f)
+		ch <- readResult{data, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("unable to read %s: %w", fname, ctx.Err())
+	}
+}
+
+// readLimited reads r until EOF, failing once more than maxInputSize bytes
+// are read
+func readLimited(name string, r io.Reader) ([]byte, error) {
+	if maxInputSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxInputSize+1))
+	if err == nil && int64(len(data)) > maxInputSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes, see -max-size", name, maxInputSize)
+	}
+	return data, err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadAllTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := readAll(ctx, "stdin", r)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got the error %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("the read was cancelled after %s", d)
+	}
+	if want := "unable to read stdin: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %q, want the prefix %q", err, want)
+	}
+
+	data, err := readAll(context.Background(), "stdin", strings.NewReader("data"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestConnectivityTimeout(t *testing.T) {
+	// the server accepts the connection but never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	ca, _ := newTestCA(t, "ca")
+	fname := writeFile(t, t.TempDir(), "config", serverConfig("https://"+l.Addr().String(), ca))
+
+	start := time.Now()
+	stdout, stderr, err := runMain(t, "doctor", "-f", fname, "-check-connectivity", "-timeout", "200ms")
+	if err == nil {
+		t.Fatalf("expected an error:\n%s", stdout)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("the check was cancelled after %s", d)
+	}
+	if !strings.Contains(stdout, "deadline exceeded") && !strings.Contains(stdout, "timeout") {
+		t.Errorf("unexpected report:\n%s%s", stdout, stderr)
+	}
+
+	if _, stderr, err = runMain(t, "extract", "-f", fname, "-check-connectivity", "-timeout", "200ms"); err == nil || !strings.Contains(stderr, "unable to connect to") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	limit := strconv.Itoa(len(testConfig) - 1)
+	want := fmt.Sprintf("%s is larger than %s bytes, see -max-size", fname, limit)
+	if _, stderr, err := runMain(t, "list", "-f", fname, "-max-size", limit); err == nil || !strings.Contains(stderr, want) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err := runMain(t, "merge", "-max-size", limit, fname); err == nil || !strings.Contains(stderr, want) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+
+	for _, limit := range []string{strconv.Itoa(len(testConfig)), "0"} {
+		if _, stderr, err := runMain(t, "list", "-f", fname, "-max-size", limit); err != nil {
+			t.Errorf("-max-size %s: %v: %s", limit, err, stderr)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type contextEntry struct {
+	Current   bool   `json:"current"`
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type serverEntry struct {
+	Cluster string `json:"cluster"`
+	Server  string `json:"server"`
+}
+
+type authEntry struct {
+	User    string   `json:"user"`
+	Methods []string `json:"methods"`
+}
+
+type counts struct {
+	Clusters int `json:"clusters"`
+	Contexts int `json:"contexts"`
+	Users    int `json:"users"`
+}
+
+func runList(args []string) error {
+	var (
+		count     bool
+		namespace string
+		servers   bool
+		listNS    bool
+		color     bool
+		noColor   bool
+		usedBy    string
+		listAuth  bool
+	)
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	in := addInputFlags(fs)
+	addFormatFlag(fs)
+	fs.BoolVar(&count, "count", false, "print the number of clusters, contexts and users")
+	fs.StringVar(&namespace, "namespace", "", "only list the contexts using the given namespace")
+	fs.BoolVar(&servers, "servers", false, "list the servers of all the clusters instead of the contexts")
+	fs.BoolVar(&listNS, "namespaces", false, "list the distinct namespaces set on the contexts")
+	fs.BoolVar(&listAuth, "auth-methods", false, "list the authentication methods of the users")
+	fs.StringVar(&usedBy, "used-by", "", "list the contexts referencing the given user=NAME or cluster=NAME")
+	fs.BoolVar(&color, "color", false, "color the current context and the insecure clusters even if stdout is not a terminal")
+	fs.BoolVar(&noColor, "no-color", false, "never color the output")
+	fs.Parse(args)
+	colorOutput = useColor(color, noColor)
+	if err := checkFormat(); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+
+	if count {
+		n := counts{
+			Clusters: len(cfg.Clusters),
+			Contexts: len(cfg.Contexts),
+			Users:    len(cfg.Users),
+		}
+		if outputFormat == "json" {
+			return printJSON(os.Stdout, n)
+		}
+		_, err = fmt.Printf("clusters: %d\ncontexts: %d\nusers: %d\n", n.Clusters, n.Contexts, n.Users)
+		return err
+	}
+
+	if servers {
+		return listServers(cfg)
+	}
+	if listNS {
+		return listNamespaces(cfg)
+	}
+	if usedBy != "" {
+		return listUsedBy(cfg, usedBy)
+	}
+	if listAuth {
+		return listAuthMethods(cfg)
+	}
+
+	entries := make([]contextEntry, 0, len(cfg.Contexts))
+	for _, ctx := range cfg.Contexts {
+		if namespace != "" && ctx.Context.Namespace != namespace {
+			continue
+		}
+		entries = append(entries, contextEntry{
+			Current:   ctx.Name == cfg.CurrentContext,
+			Name:      ctx.Name,
+			Cluster:   ctx.Context.Cluster,
+			User:      ctx.Context.User,
+			Namespace: ctx.Context.Namespace,
+		})
+	}
+	if outputFormat == "json" {
+		return printJSON(os.Stdout, entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "CURRENT\t%s\t%s\tUSER\tNAMESPACE\n", paint("NAME", colorDefault), paint("CLUSTER", colorDefault))
+	for _, e := range entries {
+		current, name := "", paint(e.Name, colorDefault)
+		if e.Current {
+			current, name = "*", paint(e.Name, colorGreen)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", current, name, paintCluster(cfg, e.Cluster), e.User, e.Namespace)
+	}
+	return w.Flush()
+}
+
+func listServers(cfg *Config) error {
+	entries := make([]serverEntry, 0, len(cfg.Clusters))
+	for _, cluster := range cfg.Clusters {
+		entries = append(entries, serverEntry{
+			Cluster: cluster.Name,
+			Server:  cluster.Cluster.Server,
+		})
+	}
+	if outputFormat == "json" {
+		return printJSON(os.Stdout, entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tSERVER\n", paint("CLUSTER", colorDefault))
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\n", paintCluster(cfg, e.Cluster), e.Server)
+	}
+	return w.Flush()
+}
+
+func listNamespaces(cfg *Config) error {
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, ctx := range cfg.Contexts {
+		ns := ctx.Context.Namespace
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+	if outputFormat == "json" {
+		return printJSON(os.Stdout, namespaces)
+	}
+	for _, ns := range namespaces {
+		fmt.Println(ns)
+	}
+	return nil
+}
+
+// listAuthMethods lists how each user authenticates, the users without
+// credentials are anonymous
+func listAuthMethods(cfg *Config) error {
+	entries := make([]authEntry, 0, len(cfg.Users))
+	for _, user := range cfg.Users {
+		methods := authMethods(user.User)
+		if len(methods) == 0 {
+			methods = []string{"anonymous"}
+		}
+		entries = append(entries, authEntry{User: user.Name, Methods: methods})
+	}
+	if outputFormat == "json" {
+		return printJSON(os.Stdout, entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "USER\tMETHODS\n")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\n", e.User, strings.Join(e.Methods, ","))
+	}
+	return w.Flush()
+}
+
+// listUsedBy lists the contexts referencing the user or the cluster given
+// as kind=name, to know what deleting it would break
+func listUsedBy(cfg *Config, ref string) error {
+	kind, name, ok := strings.Cut(ref, "=")
+	if !ok || name == "" || kind != "user" && kind != "cluster" {
+		return fmt.Errorf("unable to parse %q, expected user=NAME or cluster=NAME", ref)
+	}
+	contexts := []string{}
+	for _, ctx := range cfg.Contexts {
+		if kind == "user" && ctx.Context.User == name || kind == "cluster" && ctx.Context.Cluster == name {
+			contexts = append(contexts, ctx.Name)
+		}
+	}
+	// the dangling references are still listed
+	missing := kind == "user" && cfg.FindUser(name) == nil || kind == "cluster" && cfg.FindCluster(name) == nil
+	if missing && len(contexts) == 0 {
+		return &NotFoundError{kind, name}
+	}
+	if outputFormat == "json" {
+		return printJSON(os.Stdout, contexts)
+	}
+	for _, name := range contexts {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// paintCluster colors the insecure clusters in red
+func paintCluster(cfg *Config, name string) string {
+	if cluster := cfg.FindCluster(name); cluster != nil && cluster.Cluster.InsecureSkipTLSVerify {
+		return paint(name, colorRed)
+	}
+	return paint(name, colorDefault)
+}
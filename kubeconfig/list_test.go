@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestListCount(t *testing.T) {
+	stdout, stderr, err := runMain(t, "list", "-f", "testdata/rancher.yaml", "-count")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "clusters: 3\ncontexts: 3\nusers: 1\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	stdout, stderr, err = runMain(t, "list", "-f", "testdata/rancher.yaml", "-count", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var n counts
+	if err = json.Unmarshal([]byte(stdout), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != (counts{Clusters: 3, Contexts: 3, Users: 1}) {
+		t.Errorf("got %+v", n)
+	}
+}
+
+func TestListNamespace(t *testing.T) {
+	config := strings.Replace(testConfig, "    user: bob\n", "    user: bob\n    namespace: team\n", 1)
+	fname := writeFile(t, t.TempDir(), "config", config)
+	stdout, stderr, err := runMain(t, "list", "-f", fname, "-namespace", "team", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var entries []contextEntry
+	if err = json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "prod" {
+		t.Errorf("got %+v, want the prod context only", entries)
+	}
+}
+
+func TestListServers(t *testing.T) {
+	stdout, stderr, err := runMain(t, "list", "-f", "testdata/rancher.yaml", "-servers")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `CLUSTER     SERVER
+prod        https://rancher.example.com/k8s/clusters/c-x7k2p
+prod-node1  https://10.0.0.11:6443
+prod-node2  https://10.0.0.12:6443
+`
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	stdout, stderr, err = runMain(t, "list", "-f", "testdata/rancher.yaml", "-servers", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var entries []serverEntry
+	if err = json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1] != (serverEntry{"prod-node1", "https://10.0.0.11:6443"}) {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestListNamespaces(t *testing.T) {
+	config := strings.Replace(testConfig, "    user: bob\n", "    user: bob\n    namespace: team\n", 1)
+	config = strings.Replace(config, "    user: alice\n", "    user: alice\n    namespace: apps\n", 1)
+	config = strings.Replace(config, "current-context: dev\n", `- name: staging
+  context:
+    cluster: dev
+    user: alice
+    namespace: team
+- name: default
+  context:
+    cluster: dev
+    user: alice
+current-context: dev
+`, 1)
+	fname := writeFile(t, t.TempDir(), "config", config)
+	stdout, stderr, err := runMain(t, "list", "-f", fname, "-namespaces")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "apps\nteam\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+
+	stdout, stderr, err = runMain(t, "list", "-f", "testdata/rancher.yaml", "-namespaces", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if stdout != "[]\n" {
+		t.Errorf("got %q without namespaces", stdout)
+	}
+}
+
+func TestListColor(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig, "    server: https://prod.example.com:6443\n", "    server: https://prod.example.com:6443\n    insecure-skip-tls-verify: true\n", 1))
+	stdout, stderr, err := runMain(t, "list", "-f", fname, "-color")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "\x1b[32mdev\x1b[39m") || !strings.Contains(stdout, "\x1b[31mprod\x1b[39m") {
+		t.Errorf("the current context and the insecure cluster are not colored:\n%q", stdout)
+	}
+
+	for _, args := range [][]string{{"-color", "-no-color"}, {}} {
+		stdout, stderr, err = runMain(t, append([]string{"list", "-f", fname}, args...)...)
+		if err != nil {
+			t.Fatalf("%v: %s", err, stderr)
+		}
+		if strings.Contains(stdout, "\x1b[") {
+			t.Errorf("%v: the output is colored:\n%q", args, stdout)
+		}
+	}
+}
+
+func TestListUsedBy(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+- name: prod
+  context:
+    cluster: prod
+    user: bob
+- name: prod-admin
+  context:
+    cluster: prod
+    user: alice
+current-context: dev
+users:
+- name: alice
+  user:
+    token: alice-token
+- name: bob
+  user:
+    token: bob-token
+- name: unused
+  user:
+    token: unused-token
+`)
+	for _, tt := range []struct {
+		ref, want string
+	}{
+		{"user=alice", "dev\nprod-admin\n"},
+		{"cluster=prod", "prod\nprod-admin\n"},
+		{"user=unused", ""},
+	} {
+		stdout, stderr, err := runMain(t, "list", "-f", fname, "-used-by", tt.ref)
+		if err != nil {
+			t.Fatalf("%s: %v: %s", tt.ref, err, stderr)
+		}
+		if stdout != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.ref, stdout, tt.want)
+		}
+	}
+
+	stdout, stderr, err := runMain(t, "list", "-f", fname, "-used-by", "cluster=dev", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var contexts []string
+	if err = json.Unmarshal([]byte(stdout), &contexts); err != nil || len(contexts) != 1 || contexts[0] != "dev" {
+		t.Errorf("unexpected output %v:\n%s", err, stdout)
+	}
+
+	for _, tt := range []struct {
+		ref, want string
+	}{
+		{"user=nobody", `unable to find user "nobody"`},
+		{"namespace=web", `unable to parse "namespace=web", expected user=NAME or cluster=NAME`},
+	} {
+		if _, stderr, err := runMain(t, "list", "-f", fname, "-used-by", tt.ref); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%s: unexpected result %v: %s", tt.ref, err, stderr)
+		}
+	}
+}
+
+func TestListAuthMethods(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", `apiVersion: v1
+kind: Config
+users:
+- name: ci
+  user:
+    token: ci-token
+- name: admin
+  user:
+    client-certificate: admin.crt
+    client-key: admin.key
+- name: legacy
+  user:
+    username: admin
+    password: secret
+- name: gke
+  user:
+    auth-provider:
+      name: gcp
+- name: eks
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+- name: guest
+  user: {}
+- name: mixed
+  user:
+    tokenFile: /var/run/token
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+`)
+	stdout, stderr, err := runMain(t, "list", "-f", fname, "-auth-methods")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `USER    METHODS
+ci      token
+admin   client-certificate
+legacy  basic
+gke     auth-provider
+eks     exec
+guest   anonymous
+mixed   token,exec
+`
+	if stdout != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	stdout, stderr, err = runMain(t, "list", "-f", fname, "-auth-methods", "-output", "json")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	var entries []authEntry
+	if err = json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout)
+	}
+	if len(entries) != 7 || entries[5].User != "guest" || strings.Join(entries[6].Methods, ",") != "token,exec" {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+}
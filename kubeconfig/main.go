@@ -1,25 +1,27 @@
 package main
 
 import (
-	"bytes"
-	"flag"
-	"io"
+	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"encoding/base64"
-	"gopkg.in/yaml.v2"
+	"encoding/json"
+	"gopkg.in/yaml.v3"
 )
 
 type B64 []byte
 
-func (b *B64) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (b *B64) UnmarshalYAML(value *yaml.Node) error {
 	var s string
-	if err := unmarshal(&s); err != nil {
+	if err := value.Decode(&s); err != nil {
 		return err
 	}
-	data, err := base64.StdEncoding.DecodeString(s)
+	data, err := decodeB64(s)
 	if err != nil {
 		return err
 	}
@@ -27,94 +29,247 @@ func (b *B64) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+func (b *B64) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := decodeB64(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// decodeB64 accepts the variations found in the wild (blanks inside the
+// data, missing padding), the data is always marshaled back with the
+// canonical padded encoding so that configs from different sources give
+// the same output
+func decodeB64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s)%4 != 0 {
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
 func (b B64) MarshalYAML() (interface{}, error) {
 	return base64.StdEncoding.EncodeToString(b), nil
 }
 
+// String returns the decoded data, so that templates print the content
+// instead of the raw bytes
+func (b B64) String() string {
+	return string(b)
+}
+
+// configDir is the directory the relative file references of the config
+// are relative to, the one of the input file as with kubectl
+var configDir = "."
+
+func resolvePath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(configDir, filename)
+}
+
 func dataOrFile(data B64, filename string) (B64, error) {
-	if len(data) > 0 {
+	if len(data) > 0 || filename == "" {
 		return data, nil
 	}
-	b, err := ioutil.ReadFile(filename)
+	b, err := ioutil.ReadFile(resolvePath(filename))
 	if err != nil {
 		return nil, err
 	}
 	return b, nil
 }
 
+// noEmbed keeps the file references as they are when marshaling instead
+// of embedding the content of the files
+var noEmbed bool
+
+// embedCAOnly embeds the certificate authorities but keeps the file
+// references of the client certificates and keys, whatever noEmbed is
+var embedCAOnly bool
+
+func embedOrFile(data B64, filename string, keep bool) (B64, string, error) {
+	if keep && filename != "" {
+		return nil, filename, nil
+	}
+	b, err := dataOrFile(data, filename)
+	return b, "", err
+}
+
 type ClusterInfo struct {
-	CertificateAuthorityData B64    `yaml:"certificate-authority-data,omitempty"`
-	CertificateAuthority     string `yaml:"certificate-authority,omitempty"`
-	Server                   string `yaml:"server,omitempty"`
+	CertificateAuthorityData B64              `yaml:"certificate-authority-data,omitempty" json:"certificate-authority-data,omitempty"`
+	CertificateAuthority     string           `yaml:"certificate-authority,omitempty" json:"certificate-authority,omitempty"`
+	Server                   string           `yaml:"server,omitempty" json:"server,omitempty"`
+	TLSServerName            string           `yaml:"tls-server-name,omitempty" json:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool             `yaml:"insecure-skip-tls-verify,omitempty" json:"insecure-skip-tls-verify,omitempty"`
+	ProxyURL                 string           `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+	DisableCompression       bool             `yaml:"disable-compression,omitempty" json:"disable-compression,omitempty"`
+	Extensions               []NamedExtension `yaml:"extensions,omitempty" json:"extensions,omitempty"`
 }
 
 func (ci ClusterInfo) MarshalYAML() (interface{}, error) {
-	b, err := dataOrFile(ci.CertificateAuthorityData, ci.CertificateAuthority)
+	b, path, err := embedOrFile(ci.CertificateAuthorityData, ci.CertificateAuthority, noEmbed && !embedCAOnly)
 	if err != nil {
 		return nil, err
 	}
 	return struct {
-		CertificateAuthorityData B64    `yaml:"certificate-authority-data,omitempty"`
-		Server                   string `yaml:"server,omitempty"`
+		CertificateAuthorityData B64              `yaml:"certificate-authority-data,omitempty"`
+		CertificateAuthority     string           `yaml:"certificate-authority,omitempty"`
+		Server                   string           `yaml:"server,omitempty"`
+		TLSServerName            string           `yaml:"tls-server-name,omitempty"`
+		InsecureSkipTLSVerify    bool             `yaml:"insecure-skip-tls-verify,omitempty"`
+		ProxyURL                 string           `yaml:"proxy-url,omitempty"`
+		DisableCompression       bool             `yaml:"disable-compression,omitempty"`
+		Extensions               []NamedExtension `yaml:"extensions,omitempty"`
 	}{
 		CertificateAuthorityData: b,
+		CertificateAuthority:     path,
 		Server:                   ci.Server,
+		TLSServerName:            ci.TLSServerName,
+		InsecureSkipTLSVerify:    ci.InsecureSkipTLSVerify,
+		ProxyURL:                 ci.ProxyURL,
+		DisableCompression:       ci.DisableCompression,
+		Extensions:               ci.Extensions,
 	}, nil
 }
 
 type Cluster struct {
-	Name    string      `yaml:"name,omitempty"`
-	Cluster ClusterInfo `yaml:"cluster,omitempty"`
+	Name    string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Cluster ClusterInfo `yaml:"cluster,omitempty" json:"cluster,omitempty"`
 }
 
 type ContextInfo struct {
-	Cluster string `yaml:"cluster,omitempty"`
-	User    string `yaml:"user,omitempty"`
+	Cluster    string           `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+	User       string           `yaml:"user,omitempty" json:"user,omitempty"`
+	Namespace  string           `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Extensions []NamedExtension `yaml:"extensions,omitempty" json:"extensions,omitempty"`
 }
 
 type Context struct {
-	Name    string      `yaml:"name,omitempty"`
-	Context ContextInfo `yaml:"context,omitempty"`
+	Name    string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Context ContextInfo `yaml:"context,omitempty" json:"context,omitempty"`
 }
 
 type UserInfo struct {
-	ClientCertificateData B64    `yaml:"client-certificate-data,omitempty"`
-	ClientKeyData         B64    `yaml:"client-key-data,omitempty"`
-	ClientCertificate     string `yaml:"client-certificate,omitempty"`
-	ClientKey             string `yaml:"client-key,omitempty"`
+	ClientCertificate     string              `yaml:"client-certificate,omitempty" json:"client-certificate,omitempty"`
+	ClientCertificateData B64                 `yaml:"client-certificate-data,omitempty" json:"client-certificate-data,omitempty"`
+	ClientKey             string              `yaml:"client-key,omitempty" json:"client-key,omitempty"`
+	ClientKeyData         B64                 `yaml:"client-key-data,omitempty" json:"client-key-data,omitempty"`
+	Token                 string              `yaml:"token,omitempty" json:"token,omitempty"`
+	TokenFile             string              `yaml:"tokenFile,omitempty" json:"tokenFile,omitempty"`
+	Impersonate           string              `yaml:"as,omitempty" json:"as,omitempty"`
+	ImpersonateUID        string              `yaml:"as-uid,omitempty" json:"as-uid,omitempty"`
+	ImpersonateGroups     []string            `yaml:"as-groups,omitempty" json:"as-groups,omitempty"`
+	ImpersonateUserExtra  map[string][]string `yaml:"as-user-extra,omitempty" json:"as-user-extra,omitempty"`
+	Username              string              `yaml:"username,omitempty" json:"username,omitempty"`
+	Password              string              `yaml:"password,omitempty" json:"password,omitempty"`
+	AuthProvider          *AuthProvider       `yaml:"auth-provider,omitempty" json:"auth-provider,omitempty"`
+	Exec                  *ExecConfig         `yaml:"exec,omitempty" json:"exec,omitempty"`
+	Extensions            []NamedExtension    `yaml:"extensions,omitempty" json:"extensions,omitempty"`
+}
+
+type AuthProvider struct {
+	Name   string            `yaml:"name" json:"name"`
+	Config map[string]string `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// ExecConfig runs a command printing the credentials of the user, like the
+// cloud provider plugins do
+type ExecConfig struct {
+	APIVersion         string       `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Command            string       `yaml:"command" json:"command"`
+	Args               []string     `yaml:"args,omitempty" json:"args,omitempty"`
+	Env                []ExecEnvVar `yaml:"env,omitempty" json:"env,omitempty"`
+	InstallHint        string       `yaml:"installHint,omitempty" json:"installHint,omitempty"`
+	ProvideClusterInfo bool         `yaml:"provideClusterInfo,omitempty" json:"provideClusterInfo,omitempty"`
+	InteractiveMode    string       `yaml:"interactiveMode,omitempty" json:"interactiveMode,omitempty"`
+}
+
+type ExecEnvVar struct {
+	Name  string `yaml:"name" json:"name"`
+	Value string `yaml:"value" json:"value"`
 }
 
 func (ui UserInfo) MarshalYAML() (interface{}, error) {
-	cert, err := dataOrFile(ui.ClientCertificateData, ui.ClientCertificate)
+	cert, certPath, err := embedOrFile(ui.ClientCertificateData, ui.ClientCertificate, noEmbed || embedCAOnly)
 	if err != nil {
 		return nil, err
 	}
-	key, err := dataOrFile(ui.ClientKeyData, ui.ClientKey)
+	key, keyPath, err := embedOrFile(ui.ClientKeyData, ui.ClientKey, noEmbed || embedCAOnly)
 	if err != nil {
 		return nil, err
 	}
+	// the fields are emitted in the same order as kubectl does, so that
+	// the diffs stay stable
 	return struct {
-		ClientCertificateData B64 `yaml:"client-certificate-data,omitempty"`
-		ClientKeyData         B64 `yaml:"client-key-data,omitempty"`
+		ClientCertificate     string              `yaml:"client-certificate,omitempty"`
+		ClientCertificateData B64                 `yaml:"client-certificate-data,omitempty"`
+		ClientKey             string              `yaml:"client-key,omitempty"`
+		ClientKeyData         B64                 `yaml:"client-key-data,omitempty"`
+		Token                 string              `yaml:"token,omitempty"`
+		TokenFile             string              `yaml:"tokenFile,omitempty"`
+		Impersonate           string              `yaml:"as,omitempty"`
+		ImpersonateUID        string              `yaml:"as-uid,omitempty"`
+		ImpersonateGroups     []string            `yaml:"as-groups,omitempty"`
+		ImpersonateUserExtra  map[string][]string `yaml:"as-user-extra,omitempty"`
+		Username              string              `yaml:"username,omitempty"`
+		Password              string              `yaml:"password,omitempty"`
+		AuthProvider          *AuthProvider       `yaml:"auth-provider,omitempty"`
+		Exec                  *ExecConfig         `yaml:"exec,omitempty"`
+		Extensions            []NamedExtension    `yaml:"extensions,omitempty"`
 	}{
+		ClientCertificate:     certPath,
 		ClientCertificateData: cert,
+		ClientKey:             keyPath,
 		ClientKeyData:         key,
+		Token:                 ui.Token,
+		TokenFile:             ui.TokenFile,
+		Impersonate:           ui.Impersonate,
+		ImpersonateUID:        ui.ImpersonateUID,
+		ImpersonateGroups:     ui.ImpersonateGroups,
+		ImpersonateUserExtra:  ui.ImpersonateUserExtra,
+		Username:              ui.Username,
+		Password:              ui.Password,
+		AuthProvider:          ui.AuthProvider,
+		Exec:                  ui.Exec,
+		Extensions:            ui.Extensions,
 	}, nil
 }
 
 type User struct {
-	Name string   `yaml:"name,omitempty"`
-	User UserInfo `yaml:"user,omitempty"`
+	Name string   `yaml:"name,omitempty" json:"name,omitempty"`
+	User UserInfo `yaml:"user,omitempty" json:"user,omitempty"`
+}
+
+type NamedExtension struct {
+	Name      string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Extension interface{} `yaml:"extension,omitempty" json:"extension,omitempty"`
+}
+
+type Preferences struct {
+	Colors     bool             `yaml:"colors,omitempty" json:"colors,omitempty"`
+	Extensions []NamedExtension `yaml:"extensions,omitempty" json:"extensions,omitempty"`
 }
 
 type Config struct {
-	ApiVersion     string    `yaml:"apiVersion,omitempty"`
-	Clusters       []Cluster `yaml:"clusters,omitempty"`
-	Contexts       []Context `yaml:"contexts,omitempty"`
-	CurrentContext string    `yaml:"current-context,omitempty"`
-	Kind           string    `yaml:"kind,omitempty"`
-	Users          []User    `yaml:"users,omitempty"`
-	Preferences    struct{}  `yaml:"preferences,omitempty"`
+	ApiVersion     string      `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Clusters       []Cluster   `yaml:"clusters,omitempty" json:"clusters,omitempty"`
+	Contexts       []Context   `yaml:"contexts,omitempty" json:"contexts,omitempty"`
+	CurrentContext string      `yaml:"current-context,omitempty" json:"current-context,omitempty"`
+	Kind           string      `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Users          []User      `yaml:"users,omitempty" json:"users,omitempty"`
+	Preferences    Preferences `yaml:"preferences,omitempty" json:"preferences,omitempty"`
+
+	// the parsed document, to keep the comments when printing the config
+	doc *yaml.Node
+	// set by Normalize, the keys keep the order of the marshaling
+	normalized bool
 }
 
 func (c *Config) FindCluster(name string) *Cluster {
@@ -147,55 +302,427 @@ func (c *Config) FindUser(name string) *User {
 	return nil
 }
 
-func main() {
+// ClusterMap returns the clusters by name, the pointers refer to the
+// entries of c.Clusters and the first entry wins like with FindCluster
+func (c *Config) ClusterMap() map[string]*Cluster {
+	m := make(map[string]*Cluster, len(c.Clusters))
+	for i := len(c.Clusters) - 1; i >= 0; i-- {
+		m[c.Clusters[i].Name] = &c.Clusters[i]
+	}
+	return m
+}
+
+func (c *Config) ContextMap() map[string]*Context {
+	m := make(map[string]*Context, len(c.Contexts))
+	for i := len(c.Contexts) - 1; i >= 0; i-- {
+		m[c.Contexts[i].Name] = &c.Contexts[i]
+	}
+	return m
+}
+
+func (c *Config) UserMap() map[string]*User {
+	m := make(map[string]*User, len(c.Users))
+	for i := len(c.Users) - 1; i >= 0; i-- {
+		m[c.Users[i].Name] = &c.Users[i]
+	}
+	return m
+}
+
+// ContextNames returns the sorted names of the contexts, each name once
+func (c *Config) ContextNames() []string {
+	names := make([]string, 0, len(c.Contexts))
+	seen := map[string]bool{}
+	for _, ctx := range c.Contexts {
+		if !seen[ctx.Name] {
+			seen[ctx.Name] = true
+			names = append(names, ctx.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RedactServers replaces the server urls and host names of the clusters
+// with placeholders, to share a config without the internal endpoints
+func (c *Config) RedactServers() {
+	for i := range c.Clusters {
+		cluster := &c.Clusters[i].Cluster
+		if cluster.Server != "" {
+			cluster.Server = "https://REDACTED"
+		}
+		if cluster.TLSServerName != "" {
+			cluster.TLSServerName = "REDACTED"
+		}
+	}
+}
+
+// DedupContexts removes the contexts defined more than once, the first
+// definition is kept as it is the one FindContext returns, the names
+// removed are returned
+func (c *Config) DedupContexts() []string {
 	var (
-		fname   string
-		context string
+		contexts []Context
+		removed  []string
+		seen     = map[string]bool{}
 	)
-	flag.StringVar(&fname, "f", "", "input kube config file name (~/.kube/*.conf)")
-	flag.StringVar(&context, "c", "", "context name")
-	flag.Parse()
+	for _, ctx := range c.Contexts {
+		if seen[ctx.Name] {
+			removed = append(removed, ctx.Name)
+			continue
+		}
+		seen[ctx.Name] = true
+		contexts = append(contexts, ctx)
+	}
+	c.Contexts = contexts
+	return removed
+}
 
-	data, err := ioutil.ReadFile(fname)
-	if err != nil {
-		log.Fatalf("unable to read from stdin: %v", err)
+func (c *Config) AddCluster(name, server string, ca []byte) error {
+	if c.FindCluster(name) != nil {
+		return fmt.Errorf("cluster %q already exists", name)
 	}
+	c.Clusters = append(c.Clusters, Cluster{
+		Name: name,
+		Cluster: ClusterInfo{
+			CertificateAuthorityData: ca,
+			Server:                   server,
+		},
+	})
+	return nil
+}
 
-	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
-	if err != nil {
-		log.Fatalf("unable to load config: %v", err)
+func (c *Config) AddContext(name, cluster, user, namespace string) error {
+	if c.FindContext(name) != nil {
+		return fmt.Errorf("context %q already exists", name)
 	}
+	c.Contexts = append(c.Contexts, Context{
+		Name: name,
+		Context: ContextInfo{
+			Cluster:   cluster,
+			User:      user,
+			Namespace: namespace,
+		},
+	})
+	return nil
+}
 
-	// find
-	ctx := cfg.FindContext(context)
-	if ctx == nil {
-		log.Fatalf("unable to find context %q", context)
+func (c *Config) AddUser(name string, info UserInfo) error {
+	if c.FindUser(name) != nil {
+		return fmt.Errorf("user %q already exists", name)
 	}
-	cfg.Contexts = []Context{*ctx}
+	c.Users = append(c.Users, User{
+		Name: name,
+		User: info,
+	})
+	return nil
+}
 
-	cluster := cfg.FindCluster(ctx.Context.Cluster)
+// RenameCluster renames the cluster and updates the contexts referencing it
+func (c *Config) RenameCluster(name, newName string) error {
+	cluster := c.FindCluster(name)
 	if cluster == nil {
-		log.Fatalf("unable to find cluster %q", ctx.Context.Cluster)
+		return &NotFoundError{"cluster", name}
+	}
+	if c.FindCluster(newName) != nil {
+		return fmt.Errorf("cluster %q already exists", newName)
 	}
-	cfg.Clusters = []Cluster{*cluster}
+	cluster.Name = newName
+	for i := range c.Contexts {
+		if c.Contexts[i].Context.Cluster == name {
+			c.Contexts[i].Context.Cluster = newName
+		}
+	}
+	return nil
+}
+
+// RenameContext renames the context, and the current context if it is the
+// renamed one
+func (c *Config) RenameContext(name, newName string) error {
+	ctx := c.FindContext(name)
+	if ctx == nil {
+		return &NotFoundError{"context", name}
+	}
+	if c.FindContext(newName) != nil {
+		return fmt.Errorf("context %q already exists", newName)
+	}
+	ctx.Name = newName
+	if c.CurrentContext == name {
+		c.CurrentContext = newName
+	}
+	return nil
+}
 
-	user := cfg.FindUser(ctx.Context.User)
+// RenameUser renames the user and updates the contexts referencing it
+func (c *Config) RenameUser(name, newName string) error {
+	user := c.FindUser(name)
 	if user == nil {
-		log.Fatalf("unable to find user %q", ctx.Context.User)
+		return &NotFoundError{"user", name}
+	}
+	if c.FindUser(newName) != nil {
+		return fmt.Errorf("user %q already exists", newName)
+	}
+	user.Name = newName
+	for i := range c.Contexts {
+		if c.Contexts[i].Context.User == name {
+			c.Contexts[i].Context.User = newName
+		}
 	}
-	cfg.Users = []User{*user}
+	return nil
+}
 
-	cfg.CurrentContext = context
+// RemoveContext removes the context, with prune the cluster and the user it
+// references are removed too when no other context references them. The
+// current context is unset when it is the removed one
+func (c *Config) RemoveContext(name string, prune bool) error {
+	if c.FindContext(name) == nil {
+		return &NotFoundError{"context", name}
+	}
+	var contexts []Context
+	orphans := map[Conflict]bool{}
+	for _, ctx := range c.Contexts {
+		if ctx.Name != name {
+			contexts = append(contexts, ctx)
+			continue
+		}
+		orphans[Conflict{"cluster", ctx.Context.Cluster}] = true
+		orphans[Conflict{"user", ctx.Context.User}] = true
+	}
+	c.Contexts = contexts
+	if c.CurrentContext == name {
+		c.CurrentContext = ""
+	}
+	if !prune {
+		return nil
+	}
 
-	// output
-	data, err = yaml.Marshal(cfg)
-	if err != nil {
-		log.Fatalf("unable to marshal config: %v", err)
+	// the entries which were already unused are left alone
+	for _, ctx := range c.Contexts {
+		delete(orphans, Conflict{"cluster", ctx.Context.Cluster})
+		delete(orphans, Conflict{"user", ctx.Context.User})
+	}
+	var clusters []Cluster
+	for _, cluster := range c.Clusters {
+		if !orphans[Conflict{"cluster", cluster.Name}] {
+			clusters = append(clusters, cluster)
+		}
 	}
+	var users []User
+	for _, user := range c.Users {
+		if !orphans[Conflict{"user", user.Name}] {
+			users = append(users, user)
+		}
+	}
+	c.Clusters, c.Users = clusters, users
+	return nil
+}
 
-	_, err = io.Copy(os.Stdout, bytes.NewReader(data))
-	if err != nil {
-		log.Fatalf("unable to marshal config: %v", err)
+// Validate checks that every context references an existing cluster and
+// user, all the dangling references are reported at once
+func (c *Config) Validate() error {
+	var errs []error
+	// a truncated config is reported once instead of for every context
+	noClusters := len(c.Contexts) > 0 && len(c.Clusters) == 0
+	if noClusters {
+		errs = append(errs, errors.New("config has no clusters defined"))
+	}
+	noUsers := len(c.Contexts) > 0 && len(c.Users) == 0
+	if noUsers {
+		errs = append(errs, errors.New("config has no users defined"))
+	}
+	for _, ctx := range c.Contexts {
+		switch {
+		case ctx.Context.Cluster == "":
+			errs = append(errs, fmt.Errorf("context %q has no cluster set", ctx.Name))
+		case noClusters:
+		case c.FindCluster(ctx.Context.Cluster) == nil:
+			errs = append(errs, fmt.Errorf("context %q: %w", ctx.Name, &NotFoundError{"cluster", ctx.Context.Cluster}))
+		}
+		switch {
+		case ctx.Context.User == "":
+			errs = append(errs, fmt.Errorf("context %q has no user set", ctx.Name))
+		case noUsers:
+		case c.FindUser(ctx.Context.User) == nil:
+			errs = append(errs, fmt.Errorf("context %q: %w", ctx.Name, &NotFoundError{"user", ctx.Context.User}))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Select drops everything but the given contexts along with the clusters
+// and the users they reference
+func (c *Config) Select(names ...string) error {
+	contexts := make([]Context, 0, len(names))
+	for _, name := range names {
+		ctx := c.FindContext(name)
+		if ctx == nil {
+			return &NotFoundError{"context", name}
+		}
+		contexts = append(contexts, *ctx)
+	}
+	c.Contexts = contexts
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	var (
+		clusters     []Cluster
+		users        []User
+		seenClusters = map[string]bool{}
+		seenUsers    = map[string]bool{}
+	)
+	for _, ctx := range contexts {
+		if !seenClusters[ctx.Context.Cluster] {
+			seenClusters[ctx.Context.Cluster] = true
+			clusters = append(clusters, *c.FindCluster(ctx.Context.Cluster))
+		}
+		if !seenUsers[ctx.Context.User] {
+			seenUsers[ctx.Context.User] = true
+			users = append(users, *c.FindUser(ctx.Context.User))
+		}
+	}
+	c.Clusters = clusters
+	c.Users = users
+	if c.FindContext(c.CurrentContext) == nil && len(contexts) > 0 {
+		c.CurrentContext = contexts[0].Name
+	}
+	c.doc = nil
+	return nil
+}
+
+// Minify drops everything but the given context along with the cluster
+// and the user it references, and makes it the current context
+func (c *Config) Minify(context string) error {
+	if err := c.Select(context); err != nil {
+		return err
+	}
+	c.CurrentContext = context
+	return nil
+}
+
+// DeepCopy returns a copy of the config sharing no data with c, except
+// the extension values and the exec and auth-provider settings which are
+// never modified in place
+func (c *Config) DeepCopy() *Config {
+	cp := *c
+	cp.Clusters = make([]Cluster, len(c.Clusters))
+	for i, cluster := range c.Clusters {
+		cluster.Cluster.CertificateAuthorityData = copyB64(cluster.Cluster.CertificateAuthorityData)
+		cluster.Cluster.Extensions = copyExtensions(cluster.Cluster.Extensions)
+		cp.Clusters[i] = cluster
+	}
+	cp.Contexts = make([]Context, len(c.Contexts))
+	for i, ctx := range c.Contexts {
+		ctx.Context.Extensions = copyExtensions(ctx.Context.Extensions)
+		cp.Contexts[i] = ctx
+	}
+	cp.Users = make([]User, len(c.Users))
+	for i, user := range c.Users {
+		user.User.ClientCertificateData = copyB64(user.User.ClientCertificateData)
+		user.User.ClientKeyData = copyB64(user.User.ClientKeyData)
+		user.User.ImpersonateGroups = copyStrings(user.User.ImpersonateGroups)
+		if extra := user.User.ImpersonateUserExtra; extra != nil {
+			user.User.ImpersonateUserExtra = make(map[string][]string, len(extra))
+			for k, v := range extra {
+				user.User.ImpersonateUserExtra[k] = copyStrings(v)
+			}
+		}
+		user.User.Extensions = copyExtensions(user.User.Extensions)
+		cp.Users[i] = user
+	}
+	cp.Preferences.Extensions = copyExtensions(c.Preferences.Extensions)
+	return &cp
+}
+
+func copyExtensions(exts []NamedExtension) []NamedExtension {
+	if exts == nil {
+		return nil
+	}
+	return append([]NamedExtension{}, exts...)
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+func copyB64(b B64) B64 {
+	if b == nil {
+		return nil
+	}
+	return append(B64{}, b...)
+}
+
+// ForEachContext calls fn with a copy of the config minified to each
+// context in turn, c is left untouched
+func (c *Config) ForEachContext(fn func(name string, single *Config) error) error {
+	for _, ctx := range c.Contexts {
+		single := c.DeepCopy()
+		if err := single.Minify(ctx.Name); err != nil {
+			return err
+		}
+		if err := fn(ctx.Name, single); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type command struct {
+	name   string
+	usage  string
+	run    func(args []string) error
+	hidden bool
+}
+
+var commands = []command{
+	{"extract", "extract a context along with its cluster and user", runExtract, false},
+	{"list", "list the contexts", runList, false},
+	{"merge", "merge several configs, the first one defining an entry wins", runMerge, false},
+	{"split", "write each context into its own config file", runSplit, false},
+	{"set-cluster", "set the server and certificate authority of a cluster", runSetCluster, false},
+	{"set-credentials", "set the client credentials of a user", runSetCredentials, false},
+	{"set-namespace", "set the namespace of contexts", runSetNamespace, false},
+	{"rename", "rename a cluster, context or user and update the references to it", runRename, false},
+	{"delete-context", "remove contexts, and optionally the clusters and users left unused", runDeleteContext, false},
+	{"make-insecure", "disable the tls verification of a cluster", runMakeInsecure, false},
+	{"write-pem", "write the client certificate and key of a context user to files", runWritePEM, false},
+	{"doctor", "run all the checks of a context and report the result of each", runDoctor, false},
+	{"version", "print the version", runVersion, false},
+	{"complete", "print the context names starting with a prefix, for shell completion", runComplete, true},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+func main() {
+	args := os.Args[1:]
+	name := "extract"
+	switch {
+	case len(args) == 0:
+	case args[0] == "-version" || args[0] == "--version":
+		name, args = "version", args[1:]
+	case !strings.HasPrefix(args[0], "-"):
+		// flags without a command are kept for the extract command
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				fail(err)
+			}
+			return
+		}
 	}
+	usage()
+	os.Exit(exitUsage)
 }
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs main instead of the tests when the test binary is started
+// by runMain, so that the tests see the real output and exit status
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBECONFIG_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the command line with the given arguments and returns what
+// it printed, err is set when it exited with an error
+func runMain(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	return runMainInput(t, "", args...)
+}
+
+// runMainInput is runMain with stdin reading from the given content
+func runMainInput(t *testing.T, stdin string, args ...string) (string, string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	fname := filepath.Join(dir, name)
+	if err := os.WriteFile(fname, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+// newTestCert returns a self-signed certificate valid for an hour and its
+// key
+func newTestCert(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+const testConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+- name: prod
+  context:
+    cluster: prod
+    user: bob
+current-context: dev
+users:
+- name: alice
+  user:
+    token: alice-token
+- name: bob
+  user:
+    token: bob-token
+`
+
+func TestValidateReportsAllDanglingReferences(t *testing.T) {
+	cfg := Config{
+		Clusters: []Cluster{{Name: "dev"}},
+		Users:    []User{{Name: "alice"}},
+		Contexts: []Context{
+			{Name: "a", Context: ContextInfo{Cluster: "dev", User: "nobody"}},
+			{Name: "b", Context: ContextInfo{Cluster: "gone", User: "nobody"}},
+		},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`context "a": unable to find user "nobody"`,
+		`context "b": unable to find cluster "gone"`,
+		`context "b": unable to find user "nobody"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q is not reported in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateEmptyReferences(t *testing.T) {
+	cfg := Config{
+		Clusters: []Cluster{{Name: "dev"}},
+		Users:    []User{{Name: "alice"}},
+		Contexts: []Context{
+			{Name: "no-cluster", Context: ContextInfo{User: "alice"}},
+			{Name: "no-user", Context: ContextInfo{Cluster: "dev"}},
+		},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`context "no-cluster" has no cluster set`,
+		`context "no-user" has no user set`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q is not reported in:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "unable to find") {
+		t.Errorf("the empty names are looked up:\n%v", err)
+	}
+
+	if err = cfg.Minify("no-cluster"); err == nil || !strings.Contains(err.Error(), "has no cluster set") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidateTruncatedConfig(t *testing.T) {
+	fragment := testConfig[:strings.Index(testConfig, "clusters:")] + testConfig[strings.Index(testConfig, "contexts:"):]
+	fname := writeFile(t, t.TempDir(), "config", fragment)
+	_, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(stderr, "config has no clusters defined") {
+		t.Errorf("the missing clusters are not reported: %s", stderr)
+	}
+	if strings.Contains(stderr, "unable to find cluster") || strings.Contains(stderr, "no users") {
+		t.Errorf("unexpected error: %s", stderr)
+	}
+}
+
+func TestAddEntries(t *testing.T) {
+	var cfg Config
+	if err := cfg.AddCluster("dev", "https://dev.example.com", []byte("ca")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddUser("alice", UserInfo{Token: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddContext("dev", "dev", "alice", "team"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if err := cfg.AddCluster("dev", "https://other.example.com", nil); err == nil {
+		t.Error("expected an error on a duplicate cluster")
+	}
+	if err := cfg.AddUser("alice", UserInfo{}); err == nil {
+		t.Error("expected an error on a duplicate user")
+	}
+	if err := cfg.AddContext("dev", "dev", "alice", ""); err == nil {
+		t.Error("expected an error on a duplicate context")
+	}
+	if len(cfg.Clusters) != 1 || len(cfg.Users) != 1 || len(cfg.Contexts) != 1 {
+		t.Errorf("the duplicates are added: %+v", cfg)
+	}
+	if cfg.Clusters[0].Cluster.Server != "https://dev.example.com" {
+		t.Errorf("the existing cluster is replaced: %+v", cfg.Clusters[0])
+	}
+}
+
+func TestEntryMaps(t *testing.T) {
+	cfg := Config{
+		Clusters: []Cluster{{Name: "dev"}, {Name: "prod"}, {Name: "dev", Cluster: ClusterInfo{Server: "https://other.example.com"}}},
+		Contexts: []Context{{Name: "dev", Context: ContextInfo{Cluster: "dev", User: "alice"}}},
+		Users:    []User{{Name: "alice"}, {Name: "bob"}},
+	}
+	clusters := cfg.ClusterMap()
+	if len(clusters) != 2 || clusters["dev"] != &cfg.Clusters[0] || clusters["prod"] != &cfg.Clusters[1] {
+		t.Errorf("unexpected clusters %v", clusters)
+	}
+	contexts := cfg.ContextMap()
+	if len(contexts) != 1 || contexts["dev"] != &cfg.Contexts[0] {
+		t.Errorf("unexpected contexts %v", contexts)
+	}
+	users := cfg.UserMap()
+	if len(users) != 2 || users["alice"] != &cfg.Users[0] || users["bob"] != &cfg.Users[1] {
+		t.Errorf("unexpected users %v", users)
+	}
+
+	users["bob"].User.Token = "bob-token"
+	if cfg.Users[1].User.Token != "bob-token" {
+		t.Error("the map does not refer to the entries of the config")
+	}
+}
+
+func TestCommandDispatch(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	tmpl := "{{range .Contexts}}{{.Name}}{{end}}"
+
+	extracted, stderr, err := runMain(t, "extract", "-f", fname, "-c", "prod", "-template", tmpl)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if extracted != "prod" {
+		t.Errorf("extract: got %q", extracted)
+	}
+	// the flags without a command still extract
+	legacy, stderr, err := runMain(t, "-f", fname, "-c", "prod", "-template", tmpl)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if legacy != extracted {
+		t.Errorf("no command: got %q, want %q", legacy, extracted)
+	}
+
+	listed, stderr, err := runMain(t, "list", "-f", fname)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(listed), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "CURRENT") ||
+		strings.Join(strings.Fields(lines[1]), " ") != "* dev dev alice" {
+		t.Errorf("list: unexpected output:\n%s", listed)
+	}
+
+	version, _, err := runMain(t, "version")
+	if err != nil || !strings.HasPrefix(version, "kubeconfig ") {
+		t.Errorf("version: got %q, %v", version, err)
+	}
+
+	_, stderr, err = runMain(t, "unknown")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("unknown: expected the exit status 2, got %v", err)
+	}
+	if !strings.Contains(stderr, "commands:") {
+		t.Errorf("unknown: the usage is not printed: %q", stderr)
+	}
+}
+
+func TestPreferencesExtensions(t *testing.T) {
+	config := testConfig + `preferences:
+  colors: true
+  extensions:
+  - name: plugin
+    extension:
+      last-used: prod
+`
+	fname := writeFile(t, t.TempDir(), "config", config)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := `preferences:
+  colors: true
+  extensions:
+    - name: plugin
+      extension:
+        last-used: prod
+`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("the preferences are not kept:\n%s", stdout)
+	}
+}
+
+func TestCanonicalBase64(t *testing.T) {
+	for _, data := range []string{"aGVsbG8=", "aGVsbG8", "aGVs bG8=", "\"aGVs\\nbG8\""} {
+		fname := writeFile(t, t.TempDir(), "config", strings.Replace(testConfig,
+			"server: https://dev.example.com:6443", "server: https://dev.example.com:6443\n    certificate-authority-data: "+data, 1))
+		stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+		if err != nil {
+			t.Fatalf("%s: %v: %s", data, err, stderr)
+		}
+		if !strings.Contains(stdout, "certificate-authority-data: aGVsbG8=\n") {
+			t.Errorf("%s: the data is not canonical:\n%s", data, stdout)
+		}
+	}
+
+	if _, err := decodeB64("aGVsbG8!"); err == nil {
+		t.Error("expected an error on invalid data")
+	}
+}
+
+func TestDedupContexts(t *testing.T) {
+	cfg := Config{Contexts: []Context{
+		{Name: "dev", Context: ContextInfo{Cluster: "dev"}},
+		{Name: "prod"},
+		{Name: "dev", Context: ContextInfo{Cluster: "other"}},
+		{Name: "dev"},
+	}}
+	removed := cfg.DedupContexts()
+	if len(removed) != 2 || removed[0] != "dev" || removed[1] != "dev" {
+		t.Errorf("got the removed contexts %v", removed)
+	}
+	if len(cfg.Contexts) != 2 || cfg.Contexts[0].Context.Cluster != "dev" || cfg.Contexts[1].Name != "prod" {
+		t.Errorf("unexpected contexts %+v", cfg.Contexts)
+	}
+
+	duplicated := strings.Replace(testConfig, "current-context: dev\n", `- name: dev
+  context:
+    cluster: prod
+    user: bob
+current-context: dev
+`, 1)
+	fname := writeFile(t, t.TempDir(), "config", duplicated)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "*", "-dedup-contexts", "-template", "{{range .Contexts}}{{.Name}}:{{.Context.Cluster}} {{end}}")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "dev:dev prod:prod "; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, `removed duplicate context "dev"`) {
+		t.Errorf("the duplicate is not reported:\n%s", stderr)
+	}
+}
+
+func TestForEachContext(t *testing.T) {
+	cfg, err := loadConfig("testdata/rancher.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = cfg.ForEachContext(func(name string, single *Config) error {
+		names = append(names, name)
+		if len(single.Contexts) != 1 || single.Contexts[0].Name != name || single.CurrentContext != name ||
+			len(single.Clusters) != 1 || len(single.Users) != 1 {
+			t.Errorf("%s: unexpected config %+v", name, single)
+		}
+		single.Users[0].User.Token = "changed"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "prod prod-node1 prod-node2" {
+		t.Errorf("got the contexts %v", names)
+	}
+	if len(cfg.Contexts) != 3 || len(cfg.Clusters) != 3 || cfg.Users[0].User.Token == "changed" {
+		t.Errorf("the config is changed: %+v", cfg)
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	cfg := &Config{
+		Clusters: []Cluster{{Name: "dev", Cluster: ClusterInfo{
+			CertificateAuthorityData: B64("ca"),
+			Extensions:               []NamedExtension{{Name: "info", Extension: "x"}},
+		}}},
+		Contexts: []Context{{Name: "dev", Context: ContextInfo{Extensions: []NamedExtension{{Name: "info"}}}}},
+		Users: []User{{Name: "admin", User: UserInfo{
+			ClientKeyData:        B64("key"),
+			ImpersonateGroups:    []string{"system:masters"},
+			ImpersonateUserExtra: map[string][]string{"reason": {"debugging"}},
+		}}},
+	}
+	cp := cfg.DeepCopy()
+	cp.Clusters[0].Cluster.CertificateAuthorityData[0] = 'x'
+	cp.Clusters[0].Cluster.Extensions[0].Name = "changed"
+	cp.Contexts[0].Context.Extensions[0].Name = "changed"
+	cp.Users[0].User.ClientKeyData[0] = 'x'
+	cp.Users[0].User.ImpersonateGroups[0] = "changed"
+	cp.Users[0].User.ImpersonateUserExtra["reason"][0] = "changed"
+	cp.Users[0].User.ImpersonateUserExtra["other"] = nil
+
+	if string(cfg.Clusters[0].Cluster.CertificateAuthorityData) != "ca" ||
+		cfg.Clusters[0].Cluster.Extensions[0].Name != "info" ||
+		cfg.Contexts[0].Context.Extensions[0].Name != "info" ||
+		string(cfg.Users[0].User.ClientKeyData) != "key" ||
+		cfg.Users[0].User.ImpersonateGroups[0] != "system:masters" ||
+		cfg.Users[0].User.ImpersonateUserExtra["reason"][0] != "debugging" ||
+		len(cfg.Users[0].User.ImpersonateUserExtra) != 1 {
+		t.Errorf("the copy shares data with the config: %+v", cfg)
+	}
+}
+
+func TestContextNames(t *testing.T) {
+	cfg := &Config{Contexts: []Context{{Name: "staging"}, {Name: "dev"}, {Name: "prod"}, {Name: "dev"}}}
+	if got, want := strings.Join(cfg.ContextNames(), ","), "dev,prod,staging"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if names := (&Config{}).ContextNames(); names == nil || len(names) != 0 {
+		t.Errorf("got %#v without contexts", names)
+	}
+}
+
+func TestRemoveContext(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			Clusters: []Cluster{{Name: "dev"}, {Name: "prod"}, {Name: "unused"}},
+			Contexts: []Context{
+				{Name: "dev", Context: ContextInfo{Cluster: "dev", User: "alice"}},
+				{Name: "dev-admin", Context: ContextInfo{Cluster: "dev", User: "admin"}},
+				{Name: "prod", Context: ContextInfo{Cluster: "prod", User: "alice"}},
+			},
+			Users:          []User{{Name: "alice"}, {Name: "admin"}, {Name: "nobody"}},
+			CurrentContext: "prod",
+		}
+	}
+	names := func(cfg *Config) string {
+		var s []string
+		for _, cluster := range cfg.Clusters {
+			s = append(s, cluster.Name)
+		}
+		s = append(s, "/")
+		for _, user := range cfg.Users {
+			s = append(s, user.Name)
+		}
+		return strings.Join(s, " ")
+	}
+
+	cfg := newConfig()
+	if err := cfg.RemoveContext("prod", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Contexts) != 2 || cfg.CurrentContext != "" {
+		t.Errorf("the context is not removed: %+v", cfg)
+	}
+	if got, want := names(cfg), "dev prod unused / alice admin nobody"; got != want {
+		t.Errorf("without prune got %s, want %s", got, want)
+	}
+
+	// the cluster and user still used by other contexts, and the entries
+	// which were already unused, are kept
+	cfg = newConfig()
+	if err := cfg.RemoveContext("prod", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(cfg), "dev unused / alice admin nobody"; got != want {
+		t.Errorf("with prune got %s, want %s", got, want)
+	}
+	if err := cfg.RemoveContext("dev-admin", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(cfg), "dev unused / alice nobody"; got != want {
+		t.Errorf("with prune got %s, want %s", got, want)
+	}
+	if cfg.CurrentContext != "" {
+		t.Errorf("got the current context %q", cfg.CurrentContext)
+	}
+
+	var notFound *NotFoundError
+	if err := cfg.RemoveContext("staging", true); !errors.As(err, &notFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Conflict is an entry which exists in both configs of a merge
+type Conflict struct {
+	Kind string
+	Name string
+}
+
+// Merge adds the entries of other to c. By default, like kubectl, the
+// entries already in c take precedence and the ones of other with the same
+// name are dropped; with overwrite the entries of other replace them
+// instead, to layer overrides on a base config. Either way the names in
+// both configs are returned as conflicts
+//
+// The preferences follow the same rule: the extensions are merged by name,
+// and colors is set as soon as one of the configs sets it (an unset value
+// cannot be told apart from false)
+func (c *Config) Merge(other *Config, overwrite bool) []Conflict {
+	return NewMerger(c, overwrite).Add(other)
+}
+
+// Merger merges configs one after the other into a config, see Merge. The
+// names of the merged entries are indexed so that merging hundreds of
+// files does not rescan the entries already merged for each new one
+type Merger struct {
+	cfg       *Config
+	overwrite bool
+
+	clusters   map[string]int
+	contexts   map[string]int
+	users      map[string]int
+	extensions map[string]int
+}
+
+// NewMerger returns a merger adding the configs to cfg, which must not be
+// modified by other means while the merger is used
+func NewMerger(cfg *Config, overwrite bool) *Merger {
+	m := &Merger{
+		cfg:        cfg,
+		overwrite:  overwrite,
+		clusters:   map[string]int{},
+		contexts:   map[string]int{},
+		users:      map[string]int{},
+		extensions: map[string]int{},
+	}
+	// the first entry of a name is the one the Find methods return
+	for i := len(cfg.Clusters) - 1; i >= 0; i-- {
+		m.clusters[cfg.Clusters[i].Name] = i
+	}
+	for i := len(cfg.Contexts) - 1; i >= 0; i-- {
+		m.contexts[cfg.Contexts[i].Name] = i
+	}
+	for i := len(cfg.Users) - 1; i >= 0; i-- {
+		m.users[cfg.Users[i].Name] = i
+	}
+	for i := len(cfg.Preferences.Extensions) - 1; i >= 0; i-- {
+		m.extensions[cfg.Preferences.Extensions[i].Name] = i
+	}
+	return m
+}
+
+// Add merges other and returns the names found in both configs
+func (m *Merger) Add(other *Config) []Conflict {
+	c := m.cfg
+	var conflicts []Conflict
+	for _, cluster := range other.Clusters {
+		if i, ok := m.clusters[cluster.Name]; ok {
+			conflicts = append(conflicts, Conflict{"cluster", cluster.Name})
+			if m.overwrite {
+				c.Clusters[i] = cluster
+			}
+			continue
+		}
+		m.clusters[cluster.Name] = len(c.Clusters)
+		c.Clusters = append(c.Clusters, cluster)
+	}
+	for _, ctx := range other.Contexts {
+		if i, ok := m.contexts[ctx.Name]; ok {
+			conflicts = append(conflicts, Conflict{"context", ctx.Name})
+			if m.overwrite {
+				c.Contexts[i] = ctx
+			}
+			continue
+		}
+		m.contexts[ctx.Name] = len(c.Contexts)
+		c.Contexts = append(c.Contexts, ctx)
+	}
+	for _, user := range other.Users {
+		if i, ok := m.users[user.Name]; ok {
+			conflicts = append(conflicts, Conflict{"user", user.Name})
+			if m.overwrite {
+				c.Users[i] = user
+			}
+			continue
+		}
+		m.users[user.Name] = len(c.Users)
+		c.Users = append(c.Users, user)
+	}
+	for _, ext := range other.Preferences.Extensions {
+		if i, ok := m.extensions[ext.Name]; ok {
+			conflicts = append(conflicts, Conflict{"extension", ext.Name})
+			if m.overwrite {
+				c.Preferences.Extensions[i] = ext
+			}
+			continue
+		}
+		m.extensions[ext.Name] = len(c.Preferences.Extensions)
+		c.Preferences.Extensions = append(c.Preferences.Extensions, ext)
+	}
+	c.Preferences.Colors = c.Preferences.Colors || other.Preferences.Colors
+	if c.ApiVersion == "" {
+		c.ApiVersion = other.ApiVersion
+	}
+	if c.Kind == "" {
+		c.Kind = other.Kind
+	}
+	if c.CurrentContext == "" || m.overwrite && other.CurrentContext != "" {
+		c.CurrentContext = other.CurrentContext
+	}
+	return conflicts
+}
+
+// renameConflicts renames the clusters, contexts and users of other whose
+// names are already merged, adding a -2, -3... suffix, so that adding
+// other keeps all its entries
+func (m *Merger) renameConflicts(other *Config) {
+	free := func(name string, used func(string) bool) string {
+		for i := 2; ; i++ {
+			if n := fmt.Sprintf("%s-%d", name, i); !used(n) {
+				return n
+			}
+		}
+	}
+	for _, cluster := range other.Clusters {
+		if _, ok := m.clusters[cluster.Name]; ok {
+			_ = other.RenameCluster(cluster.Name, free(cluster.Name, func(n string) bool {
+				_, ok := m.clusters[n]
+				return ok || other.FindCluster(n) != nil
+			}))
+		}
+	}
+	for _, ctx := range other.Contexts {
+		if _, ok := m.contexts[ctx.Name]; ok {
+			_ = other.RenameContext(ctx.Name, free(ctx.Name, func(n string) bool {
+				_, ok := m.contexts[n]
+				return ok || other.FindContext(n) != nil
+			}))
+		}
+	}
+	for _, user := range other.Users {
+		if _, ok := m.users[user.Name]; ok {
+			_ = other.RenameUser(user.Name, free(user.Name, func(n string) bool {
+				_, ok := m.users[n]
+				return ok || other.FindUser(n) != nil
+			}))
+		}
+	}
+}
+
+func entryKeys(c *Config) []Conflict {
+	keys := make([]Conflict, 0, len(c.Clusters)+len(c.Contexts)+len(c.Users)+len(c.Preferences.Extensions))
+	for _, cluster := range c.Clusters {
+		keys = append(keys, Conflict{"cluster", cluster.Name})
+	}
+	for _, ctx := range c.Contexts {
+		keys = append(keys, Conflict{"context", ctx.Name})
+	}
+	for _, user := range c.Users {
+		keys = append(keys, Conflict{"user", user.Name})
+	}
+	for _, ext := range c.Preferences.Extensions {
+		keys = append(keys, Conflict{"extension", ext.Name})
+	}
+	return keys
+}
+
+func runMerge(args []string) error {
+	var (
+		verbose    bool
+		overwrite  bool
+		autoRename bool
+		outFile    string
+	)
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	fs.StringVar(&outFile, "o", "", "write the merged config to the file instead of stdout")
+	fs.BoolVar(&verbose, "verbose", false, "report the entries dropped because of name collisions")
+	fs.BoolVar(&overwrite, "overwrite", false, "let the later files win on name collisions instead of the first one")
+	fs.BoolVar(&autoRename, "auto-rename", false, "rename the colliding clusters, contexts and users with a numbered suffix instead of dropping them")
+	exclude := addExcludeFlag(fs)
+	fs.DurationVar(&ioTimeout, "timeout", ioTimeout, "timeout of the reads of the inputs")
+	fs.Int64Var(&maxInputSize, "max-size", maxInputSize, "maximum size in bytes of the inputs, 0 for no limit")
+	addOutputFlags(fs, false)
+	addErrorFormatFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: merge [flags] <file>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if err := checkFormat(); err != nil {
+		return err
+	}
+	if overwrite && autoRename {
+		return errors.New("-overwrite cannot be used with -auto-rename")
+	}
+
+	cfg := &Config{}
+	merger := NewMerger(cfg, overwrite)
+	origin := map[Conflict]string{}
+	for _, fname := range fs.Args() {
+		other, err := loadConfig(fname)
+		if err != nil {
+			return fmt.Errorf("unable to load config: %w", err)
+		}
+		// the relative references of each file are made absolute, or
+		// relative to the -o file the merged config is read from
+		if outFile != "" {
+			err = other.RelocatePaths(filepath.Dir(fname), filepath.Dir(outFile))
+		} else {
+			err = other.AbsPaths(filepath.Dir(fname))
+		}
+		if err != nil {
+			return fmt.Errorf("unable to relocate the file references: %w", err)
+		}
+		if autoRename {
+			merger.renameConflicts(other)
+		}
+		conflicts := merger.Add(other)
+		for _, c := range conflicts {
+			switch {
+			case overwrite && verbose:
+				log.Printf("%s %q of %s is replaced, %s wins", c.Kind, c.Name, origin[c], fname)
+			case verbose:
+				log.Printf("%s %q of %s is ignored, %s wins", c.Kind, c.Name, fname, origin[c])
+			}
+			if overwrite {
+				origin[c] = fname
+			}
+		}
+		for _, key := range entryKeys(other) {
+			if _, ok := origin[key]; !ok {
+				origin[key] = fname
+			}
+		}
+	}
+
+	excludeContexts(cfg, *exclude)
+
+	if outFile != "" {
+		// the references are relative to the -o file now
+		configDir = filepath.Dir(outFile)
+		if err := WriteConfig(outFile, cfg, 0600); err != nil {
+			return fmt.Errorf("unable to write config: %w", err)
+		}
+		return nil
+	}
+	if err := printConfig(os.Stdout, cfg); err != nil {
+		return fmt.Errorf("unable to marshal config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeConflicts(t *testing.T) {
+	cfg := &Config{
+		Clusters: []Cluster{{Name: "shared", Cluster: ClusterInfo{Server: "https://first.example.com"}}},
+		Users:    []User{{Name: "alice"}},
+	}
+	other := &Config{
+		Clusters: []Cluster{
+			{Name: "shared", Cluster: ClusterInfo{Server: "https://second.example.com"}},
+			{Name: "extra"},
+		},
+		Users: []User{{Name: "bob"}},
+	}
+	conflicts := cfg.Merge(other, false)
+	if len(conflicts) != 1 || conflicts[0] != (Conflict{"cluster", "shared"}) {
+		t.Errorf("got the conflicts %v", conflicts)
+	}
+	if len(cfg.Clusters) != 2 || cfg.Clusters[0].Cluster.Server != "https://first.example.com" {
+		t.Errorf("the first cluster does not win: %+v", cfg.Clusters)
+	}
+	if len(cfg.Users) != 2 {
+		t.Errorf("the users are not merged: %+v", cfg.Users)
+	}
+}
+
+func TestMergeVerbose(t *testing.T) {
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first", testConfig)
+	second := writeFile(t, dir, "second", strings.Replace(testConfig, "dev.example.com", "dev2.example.com", 1))
+	stdout, stderr, err := runMain(t, "merge", "-verbose", first, second)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "dev.example.com") || strings.Contains(stdout, "dev2.example.com") {
+		t.Errorf("the first file does not win:\n%s", stdout)
+	}
+	for _, want := range []string{
+		`cluster "dev" of ` + second + ` is ignored, ` + first + ` wins`,
+		`context "prod" of ` + second + ` is ignored, ` + first + ` wins`,
+		`user "alice" of ` + second + ` is ignored, ` + first + ` wins`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestMergePreferences(t *testing.T) {
+	cfg := &Config{Preferences: Preferences{Extensions: []NamedExtension{{Name: "plugin", Extension: "first"}}}}
+	other := &Config{Preferences: Preferences{
+		Colors:     true,
+		Extensions: []NamedExtension{{Name: "plugin", Extension: "second"}, {Name: "other", Extension: "second"}},
+	}}
+	conflicts := cfg.Merge(other, false)
+	if len(conflicts) != 1 || conflicts[0] != (Conflict{"extension", "plugin"}) {
+		t.Errorf("got the conflicts %v", conflicts)
+	}
+	if !cfg.Preferences.Colors {
+		t.Error("colors is not set")
+	}
+	exts := cfg.Preferences.Extensions
+	if len(exts) != 2 || exts[0].Extension != "first" || exts[1].Name != "other" {
+		t.Errorf("unexpected extensions %+v", exts)
+	}
+}
+
+func TestMergeOverwrite(t *testing.T) {
+	cfg := &Config{
+		Clusters:       []Cluster{{Name: "shared", Cluster: ClusterInfo{Server: "https://first.example.com"}}},
+		CurrentContext: "first",
+		Preferences:    Preferences{Extensions: []NamedExtension{{Name: "plugin", Extension: "first"}}},
+	}
+	other := &Config{
+		Clusters:       []Cluster{{Name: "shared", Cluster: ClusterInfo{Server: "https://second.example.com"}}, {Name: "extra"}},
+		CurrentContext: "second",
+		Preferences:    Preferences{Extensions: []NamedExtension{{Name: "plugin", Extension: "second"}}},
+	}
+	conflicts := cfg.Merge(other, true)
+	if len(conflicts) != 2 {
+		t.Errorf("got the conflicts %v", conflicts)
+	}
+	if len(cfg.Clusters) != 2 || cfg.Clusters[0].Cluster.Server != "https://second.example.com" {
+		t.Errorf("the later cluster does not win: %+v", cfg.Clusters)
+	}
+	if cfg.Preferences.Extensions[0].Extension != "second" {
+		t.Errorf("the later extension does not win: %+v", cfg.Preferences.Extensions)
+	}
+	if cfg.CurrentContext != "second" {
+		t.Errorf("got the current context %q", cfg.CurrentContext)
+	}
+
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first", testConfig)
+	second := writeFile(t, dir, "second", strings.Replace(testConfig, "dev.example.com", "dev2.example.com", 1))
+	third := writeFile(t, dir, "third", strings.Replace(testConfig, "dev.example.com", "dev3.example.com", 1))
+	stdout, stderr, err := runMain(t, "merge", "-overwrite", "-verbose", first, second, third)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "dev3.example.com") || strings.Contains(stdout, "dev.example.com") || strings.Contains(stdout, "dev2.example.com") {
+		t.Errorf("the last file does not win:\n%s", stdout)
+	}
+	for _, want := range []string{
+		`cluster "dev" of ` + first + ` is replaced, ` + second + ` wins`,
+		`cluster "dev" of ` + second + ` is replaced, ` + third + ` wins`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestMergeAutoRename(t *testing.T) {
+	const config = `apiVersion: v1
+kind: Config
+clusters:
+- name: default
+  cluster:
+    server: https://%s.example.com
+contexts:
+- name: default
+  context:
+    cluster: default
+    user: default
+current-context: default
+users:
+- name: default
+  user:
+    token: %s-token
+`
+	dir := t.TempDir()
+	first := writeFile(t, dir, "first", fmt.Sprintf(config, "first", "first"))
+	second := writeFile(t, dir, "second", fmt.Sprintf(config, "second", "second"))
+
+	stdout, stderr, err := runMain(t, "merge", "-auto-rename", first, second)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := parseConfig("merged", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Clusters) != 2 || len(cfg.Contexts) != 2 || len(cfg.Users) != 2 {
+		t.Fatalf("entries are dropped:\n%s", stdout)
+	}
+	ctx := cfg.FindContext("default-2")
+	if ctx == nil || ctx.Context.Cluster != "default-2" || ctx.Context.User != "default-2" {
+		t.Fatalf("the references of the renamed context are not fixed:\n%s", stdout)
+	}
+	if cfg.FindCluster("default-2").Cluster.Server != "https://second.example.com" ||
+		cfg.FindUser("default-2").User.Token != "second-token" ||
+		cfg.FindUser("default").User.Token != "first-token" {
+		t.Errorf("the entries are mixed up:\n%s", stdout)
+	}
+	if cfg.CurrentContext != "default" {
+		t.Errorf("got the current context %q", cfg.CurrentContext)
+	}
+
+	if _, stderr, err = runMain(t, "merge", "-auto-rename", "-overwrite", first, second); err == nil || !strings.Contains(stderr, "-overwrite cannot be used with -auto-rename") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+// fleetConfig returns the config of the i-th cluster of a fleet, its
+// context names and the shared admin user collide with the other configs
+func fleetConfig(i int) *Config {
+	name := fmt.Sprintf("cluster-%d", i%400)
+	return &Config{
+		ApiVersion: "v1",
+		Kind:       "Config",
+		Clusters:   []Cluster{{Name: name, Cluster: ClusterInfo{Server: fmt.Sprintf("https://10.0.%d.%d:6443", i/256, i%256)}}},
+		Contexts: []Context{
+			{Name: name, Context: ContextInfo{Cluster: name, User: "admin"}},
+			{Name: fmt.Sprintf("%s-view-%d", name, i), Context: ContextInfo{Cluster: name, User: fmt.Sprintf("viewer-%d", i)}},
+		},
+		Users: []User{
+			{Name: "admin", User: UserInfo{Token: fmt.Sprintf("admin-%d", i)}},
+			{Name: fmt.Sprintf("viewer-%d", i), User: UserInfo{Token: fmt.Sprintf("viewer-%d", i)}},
+		},
+		CurrentContext: name,
+	}
+}
+
+// naiveMerge is Merge looking the names up with the Find methods, which
+// scan the entries already merged
+func naiveMerge(c, other *Config, overwrite bool) []Conflict {
+	var conflicts []Conflict
+	for _, cluster := range other.Clusters {
+		if found := c.FindCluster(cluster.Name); found != nil {
+			conflicts = append(conflicts, Conflict{"cluster", cluster.Name})
+			if overwrite {
+				*found = cluster
+			}
+			continue
+		}
+		c.Clusters = append(c.Clusters, cluster)
+	}
+	for _, ctx := range other.Contexts {
+		if found := c.FindContext(ctx.Name); found != nil {
+			conflicts = append(conflicts, Conflict{"context", ctx.Name})
+			if overwrite {
+				*found = ctx
+			}
+			continue
+		}
+		c.Contexts = append(c.Contexts, ctx)
+	}
+	for _, user := range other.Users {
+		if found := c.FindUser(user.Name); found != nil {
+			conflicts = append(conflicts, Conflict{"user", user.Name})
+			if overwrite {
+				*found = user
+			}
+			continue
+		}
+		c.Users = append(c.Users, user)
+	}
+	if c.ApiVersion == "" {
+		c.ApiVersion = other.ApiVersion
+	}
+	if c.Kind == "" {
+		c.Kind = other.Kind
+	}
+	if c.CurrentContext == "" || overwrite && other.CurrentContext != "" {
+		c.CurrentContext = other.CurrentContext
+	}
+	return conflicts
+}
+
+func TestMergerMatchesNaiveMerge(t *testing.T) {
+	for _, overwrite := range []bool{false, true} {
+		indexed, naive := &Config{}, &Config{}
+		merger := NewMerger(indexed, overwrite)
+		for i := 0; i < 500; i++ {
+			got, want := merger.Add(fleetConfig(i)), naiveMerge(naive, fleetConfig(i), overwrite)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("overwrite %v, config %d: got the conflicts %v, want %v", overwrite, i, got, want)
+			}
+		}
+		if !reflect.DeepEqual(indexed, naive) {
+			t.Errorf("overwrite %v: the merged configs differ", overwrite)
+		}
+		if len(indexed.Clusters) != 400 || len(indexed.Contexts) != 900 || len(indexed.Users) != 501 {
+			t.Errorf("overwrite %v: got %d clusters, %d contexts and %d users", overwrite, len(indexed.Clusters), len(indexed.Contexts), len(indexed.Users))
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	dir := b.TempDir()
+	fnames := make([]string, 500)
+	for i := range fnames {
+		data, err := yaml.Marshal(fleetConfig(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		fnames[i] = filepath.Join(dir, fmt.Sprintf("cluster-%d.yaml", i))
+		if err = os.WriteFile(fnames[i], data, 0600); err != nil {
+			b.Fatal(err)
+		}
+	}
+	configs := make([]*Config, len(fnames))
+	for i, fname := range fnames {
+		cfg, err := loadConfig(fname)
+		if err != nil {
+			b.Fatal(err)
+		}
+		configs[i] = cfg
+	}
+
+	b.Run("files", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			merger := NewMerger(&Config{}, false)
+			for _, fname := range fnames {
+				cfg, err := loadConfig(fname)
+				if err != nil {
+					b.Fatal(err)
+				}
+				merger.Add(cfg)
+			}
+		}
+	})
+	b.Run("indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			merger := NewMerger(&Config{}, false)
+			for _, cfg := range configs {
+				merger.Add(cfg)
+			}
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			merged := &Config{}
+			for _, cfg := range configs {
+				naiveMerge(merged, cfg, false)
+			}
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Renaming is an entry renamed by CanonicalizeNames
+type Renaming struct {
+	Kind string
+	From string
+	To   string
+}
+
+var slugRegexp = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// slugify lowercases the name and replaces the runs of other characters
+// than letters, digits and dots with a hyphen
+func slugify(name string) string {
+	return strings.Trim(slugRegexp.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// CanonicalizeNames slugifies the names of the clusters, contexts and users
+// and updates the references to them. A slug already taken gets a -2,
+// -3... suffix. The renamings applied are returned
+func (c *Config) CanonicalizeNames() []Renaming {
+	var renamings []Renaming
+	canonicalize := func(kind, name string, used func(string) bool, rename func(string, string) error) {
+		slug := slugify(name)
+		if slug == name || slug == "" {
+			return
+		}
+		newName := slug
+		for i := 2; used(newName); i++ {
+			newName = fmt.Sprintf("%s-%d", slug, i)
+		}
+		if rename(name, newName) == nil {
+			renamings = append(renamings, Renaming{kind, name, newName})
+		}
+	}
+	for i := range c.Clusters {
+		canonicalize("cluster", c.Clusters[i].Name, func(n string) bool { return c.FindCluster(n) != nil }, c.RenameCluster)
+	}
+	for i := range c.Contexts {
+		canonicalize("context", c.Contexts[i].Name, func(n string) bool { return c.FindContext(n) != nil }, c.RenameContext)
+	}
+	for i := range c.Users {
+		canonicalize("user", c.Users[i].Name, func(n string) bool { return c.FindUser(n) != nil }, c.RenameUser)
+	}
+	return renamings
+}
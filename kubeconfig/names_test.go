@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizeNames(t *testing.T) {
+	const config = `apiVersion: v1
+kind: Config
+clusters:
+  - name: Prod Cluster
+    cluster:
+      server: https://prod.example.com:6443
+  - name: prod-cluster
+    cluster:
+      server: https://prod2.example.com:6443
+contexts:
+  - name: My Prod (admin)
+    context:
+      cluster: Prod Cluster
+      user: Bob@Corp
+  - name: prod-2
+    context:
+      cluster: prod-cluster
+      user: Bob@Corp
+current-context: My Prod (admin)
+users:
+  - name: Bob@Corp
+    user:
+      token: bob-token
+`
+	fname := writeFile(t, t.TempDir(), "config", config)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "*", "-canonicalize-names")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		`renamed cluster "Prod Cluster" to "prod-cluster-2"`,
+		`renamed context "My Prod (admin)" to "my-prod-admin"`,
+		`renamed user "Bob@Corp" to "bob-corp"`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q is not reported in:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, `"prod-2"`) {
+		t.Errorf("the canonical names are renamed:\n%s", stderr)
+	}
+
+	cfg, err := parseConfig("output", []byte(stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := cfg.FindContext("my-prod-admin")
+	if ctx == nil || ctx.Context.Cluster != "prod-cluster-2" || ctx.Context.User != "bob-corp" || cfg.CurrentContext != "my-prod-admin" {
+		t.Fatalf("the references are not updated:\n%s", stdout)
+	}
+	if ctx := cfg.FindContext("prod-2"); ctx == nil || ctx.Context.Cluster != "prod-cluster" || ctx.Context.User != "bob-corp" {
+		t.Errorf("the references are not updated:\n%s", stdout)
+	}
+	if c := cfg.FindCluster("prod-cluster-2"); c == nil || c.Cluster.Server != "https://prod.example.com:6443" {
+		t.Errorf("unexpected clusters:\n%s", stdout)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runSetNamespace(args []string) error {
+	fs := flag.NewFlagSet("set-namespace", flag.ExitOnError)
+	in := addInputFlags(fs)
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: set-namespace [flags] <context>=<namespace>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	for _, arg := range fs.Args() {
+		if !strings.Contains(arg, "=") {
+			return fmt.Errorf("invalid argument %q, expecting <context>=<namespace>", arg)
+		}
+	}
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	for _, arg := range fs.Args() {
+		// an empty namespace resets the context to the default one
+		name, namespace, _ := strings.Cut(arg, "=")
+		ctx := cfg.FindContext(name)
+		if ctx == nil {
+			return &NotFoundError{"context", name}
+		}
+		ctx.Context.Namespace = namespace
+	}
+
+	return edit.write(in, cfg)
+}
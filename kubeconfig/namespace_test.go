@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetNamespace(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", "# managed by hand\n"+strings.Replace(testConfig, "    user: bob\n", "    user: bob\n    namespace: old\n", 1))
+	if _, stderr, err := runMain(t, "set-namespace", "-f", fname, "-in-place", "dev=web", "prod="); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	cfg, err := loadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns := cfg.FindContext("dev").Context.Namespace; ns != "web" {
+		t.Errorf("got the namespace %q on dev", ns)
+	}
+	if ns := cfg.FindContext("prod").Context.Namespace; ns != "" {
+		t.Errorf("got the namespace %q on prod", ns)
+	}
+	if len(cfg.Clusters) != 2 || len(cfg.Users) != 2 {
+		t.Errorf("the config is minified: %+v", cfg)
+	}
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# managed by hand\n") {
+		t.Errorf("the comment is dropped:\n%s", data)
+	}
+
+	for _, tt := range []struct {
+		arg, want string
+	}{
+		{"staging=web", `unable to find context "staging"`},
+		{"dev", `invalid argument "dev", expecting <context>=<namespace>`},
+	} {
+		if _, stderr, err := runMain(t, "set-namespace", "-f", fname, tt.arg); err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%s: unexpected result %v: %s", tt.arg, err, stderr)
+		}
+	}
+}
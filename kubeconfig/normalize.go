@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// Normalize puts the config in a canonical form so that equivalent configs
+// print the same: the entries are sorted by name, the missing apiVersion
+// and kind are defaulted and the embedded PEM data uses unix line endings.
+// The order of the fields is fixed by the marshaling, the comments are
+// kept. Normalizing twice gives the same config
+func (c *Config) Normalize() {
+	c.normalized = true
+	if c.ApiVersion == "" {
+		c.ApiVersion = "v1"
+	}
+	if c.Kind == "" {
+		c.Kind = "Config"
+	}
+	// stable sorts keep the duplicates in the order Find sees them
+	sort.SliceStable(c.Clusters, func(i, j int) bool { return c.Clusters[i].Name < c.Clusters[j].Name })
+	sort.SliceStable(c.Contexts, func(i, j int) bool { return c.Contexts[i].Name < c.Contexts[j].Name })
+	sort.SliceStable(c.Users, func(i, j int) bool { return c.Users[i].Name < c.Users[j].Name })
+	exts := c.Preferences.Extensions
+	sort.SliceStable(exts, func(i, j int) bool { return exts[i].Name < exts[j].Name })
+
+	for i := range c.Clusters {
+		cluster := &c.Clusters[i].Cluster
+		cluster.CertificateAuthorityData = normalizePEM(cluster.CertificateAuthorityData)
+	}
+	for i := range c.Users {
+		user := &c.Users[i].User
+		user.ClientCertificateData = normalizePEM(user.ClientCertificateData)
+		user.ClientKeyData = normalizePEM(user.ClientKeyData)
+	}
+}
+
+func normalizePEM(data B64) B64 {
+	if len(data) == 0 {
+		return nil
+	}
+	if !bytes.HasPrefix(data, []byte("-----BEGIN ")) {
+		return data
+	}
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// Hash returns the hex sha256 of the normalized config, the configs which
+// only differ by their formatting, comments, base64 wrapping or order of
+// the entries hash the same. c itself is left untouched
+func (c *Config) Hash() (string, error) {
+	cp := c.DeepCopy()
+	cp.Normalize()
+	// the json encoding of the model is canonical, the maps are sorted
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const unsortedConfig = `# managed by the fleet tooling
+
+users:
+  - name: bob
+    user:
+      token: bob-token
+  - name: alice
+    user:
+      token: alice-token
+kind: Config
+contexts:
+  - name: prod
+    context:
+      user: bob
+      cluster: prod
+  # the default context
+  - name: dev
+    context:
+      user: alice
+      cluster: dev
+current-context: dev
+clusters:
+  - name: prod
+    cluster:
+      server: https://prod.example.com:6443
+  - name: dev
+    cluster:
+      server: https://dev.example.com:6443
+      certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tDQpjYQ0KLS0tLS1FTkQgQ0VSVElGSUNBVEUtLS0tLQ0K
+`
+
+func TestNormalize(t *testing.T) {
+	cfg, err := parseConfig("config", []byte(unsortedConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Normalize()
+	var first bytes.Buffer
+	if err = printConfig(&first, cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Normalize()
+	var second bytes.Buffer
+	if err = printConfig(&second, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("normalizing twice changes the config:\n%s\n%s", first.String(), second.String())
+	}
+
+	// normalizing the normalized output gives it back too
+	normalized, err := parseConfig("normalized", first.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized.Normalize()
+	var again bytes.Buffer
+	if err = printConfig(&again, normalized); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != first.String() {
+		t.Errorf("normalizing the output changes it:\n%s\n%s", first.String(), again.String())
+	}
+
+	out := first.String()
+	if !strings.HasPrefix(out, "# managed by the fleet tooling\n\napiVersion: v1\nclusters:\n  - name: dev\n") {
+		t.Errorf("the header comment is not kept or the keys are not in the canonical order:\n%s", out)
+	}
+	if !strings.Contains(out, "contexts:\n  # the default context\n  - name: dev\n    context:\n      cluster: dev\n      user: alice\n") {
+		t.Errorf("the comments of the entries are not kept:\n%s", out)
+	}
+	if cluster := normalized.FindCluster("dev"); string(cluster.Cluster.CertificateAuthorityData) != "-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----\n" {
+		t.Errorf("the line endings of the PEM data are not normalized: %q", cluster.Cluster.CertificateAuthorityData)
+	}
+	if i, j := strings.Index(out, "kind: Config"), strings.Index(out, "users:"); i < 0 || j < i {
+		t.Errorf("the keys are not in the canonical order:\n%s", out)
+	}
+
+	fname := writeFile(t, t.TempDir(), "config", unsortedConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "*", "-normalize")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.HasPrefix(stdout, "apiVersion: v1\nclusters:\n  - name: dev\n") {
+		t.Errorf("-normalize does not normalize the config:\n%s", stdout)
+	}
+}
+
+func TestHash(t *testing.T) {
+	const pemCA = "-----BEGIN CERTIFICATE-----\nca\n-----END CERTIFICATE-----\n"
+	data := base64.StdEncoding.EncodeToString([]byte(pemCA))
+	crlfData := base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(pemCA, "\n", "\r\n")))
+	config := strings.Replace(testConfig, "    server: https://dev.example.com:6443\n",
+		"    server: https://dev.example.com:6443\n    certificate-authority-data: "+data+"\n", 1)
+	// the same config with the entries in another order, comments, an
+	// unpadded base64 wrapped over several lines and CRLF line endings in
+	// the PEM data
+	reformatted := `# regenerated
+apiVersion: v1
+kind: Config
+current-context: dev
+users:
+- name: bob
+  user: {token: bob-token}
+- name: alice
+  user:
+    token: alice-token # rotated monthly
+contexts:
+- name: prod
+  context: {cluster: prod, user: bob}
+- name: dev
+  context: {cluster: dev, user: alice}
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+- name: dev
+  cluster:
+    certificate-authority-data: |
+      ` + crlfData[:20] + `
+      ` + strings.TrimRight(crlfData[20:], "=") + `
+    server: https://dev.example.com:6443
+`
+	hash := func(config string) string {
+		t.Helper()
+		stdout, stderr, err := runMain(t, "-f", writeFile(t, t.TempDir(), "config", config), "-c", "*", "-hash")
+		if err != nil {
+			t.Fatalf("%v: %s", err, stderr)
+		}
+		return stdout
+	}
+	want := hash(config)
+	if len(want) != 65 {
+		t.Fatalf("unexpected hash %q", want)
+	}
+	if got := hash(reformatted); got != want {
+		t.Errorf("the reformatted config hashes differently: %s != %s", got, want)
+	}
+	if got := hash(strings.Replace(config, "bob-token", "bob-token-2", 1)); got == want {
+		t.Error("the changed config hashes the same")
+	}
+
+	// the config itself is not normalized
+	cfg, err := parseConfig("config", []byte(reformatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cfg.Hash(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Users[0].Name != "bob" || cfg.normalized {
+		t.Errorf("Hash changes the config: %+v", cfg.Users)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+func printConfig(w io.Writer, cfg *Config) error {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return err
+	}
+	stripFields(&root)
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}
+	if compact {
+		flowStyle(&root)
+	} else if cfg.doc != nil {
+		copyComments(doc, cfg.doc, !cfg.normalized)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(indent)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteConfig marshals the config to path with the given permissions,
+// 0600 when perm is 0 as configs hold credentials. The file is replaced
+// atomically
+func WriteConfig(path string, cfg *Config, perm os.FileMode) error {
+	if perm == 0 {
+		perm = 0600
+	}
+	var buf bytes.Buffer
+	if err := printConfig(&buf, cfg); err != nil {
+		return fmt.Errorf("unable to marshal config: %w", err)
+	}
+	return writeFileAtomic(path, buf.Bytes(), perm)
+}
+
+// indent is the number of spaces of the yaml indentation, set by -indent
+var indent = 2
+
+// compact prints the config on a single line in the yaml flow style, set
+// by -compact, for the configs stored in variables or annotations
+var compact bool
+
+// flowStyle sets the flow style on the mappings and sequences of the tree,
+// the comments are dropped as they would break the line
+func flowStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = yaml.FlowStyle
+	}
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	for _, child := range node.Content {
+		flowStyle(child)
+	}
+}
+
+// MinifyToBytes returns the yaml of the config minified to the context,
+// cfg itself is left untouched
+func MinifyToBytes(cfg *Config, context string) ([]byte, error) {
+	single := *cfg
+	if err := single.Minify(context); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := printConfig(&buf, &single); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// outputFormat is set by the commands supporting -output, the errors are
+// reported as json too when it is json
+var outputFormat = "text"
+
+func addFormatFlag(fs *flag.FlagSet) {
+	fs.StringVar(&outputFormat, "output", "text", "output format (text or json)")
+}
+
+// addErrorFormatFlag registers -output on the commands with their own
+// output format, where it only selects how the errors are reported
+func addErrorFormatFlag(fs *flag.FlagSet) {
+	fs.StringVar(&outputFormat, "output", "text", "format of the errors (text or json)")
+}
+
+func checkFormat() error {
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("unknown output format %q", outputFormat)
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// addOutputFlags registers the flags controlling how the config is
+// printed, flatten tells whether the certificate files are embedded by
+// default or kept as they are in the input
+func addOutputFlags(fs *flag.FlagSet, flatten bool) {
+	fs.Func("indent", "number of spaces of the yaml indentation (default 2)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if n < 2 || n > 9 {
+			return errors.New("indent must be between 2 and 9")
+		}
+		indent = n
+		return nil
+	})
+	fs.BoolVar(&compact, "compact", false, "print the config on a single line in the yaml flow style")
+	fs.Func("strip", "comma separated fields to remove from the clusters, contexts and users (e.g. token,password)", parseStrip)
+	fs.BoolVar(&embedCAOnly, "embed-ca-only", false, "embed the certificate authorities but keep the client certificate and key files")
+	if flatten {
+		fs.BoolVar(&noEmbed, "no-embed", false, "keep the certificate file references instead of embedding them")
+		return
+	}
+	noEmbed = true
+	fs.BoolFunc("flatten", "embed the content of the certificate files", func(s string) error {
+		v, err := strconv.ParseBool(s)
+		noEmbed = !v
+		return err
+	})
+}
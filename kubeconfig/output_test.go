@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fileRefConfig writes a config whose cluster and user reference their
+// certificates by file
+func fileRefConfig(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "ca.crt", "ca")
+	writeFile(t, dir, "client.crt", "cert")
+	writeFile(t, dir, "client.key", "key")
+	return writeFile(t, dir, "config", `clusters:
+- name: dev
+  cluster:
+    certificate-authority: `+filepath.Join(dir, "ca.crt")+`
+    server: https://dev.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+current-context: dev
+users:
+- name: alice
+  user:
+    client-certificate: `+filepath.Join(dir, "client.crt")+`
+    client-key: `+filepath.Join(dir, "client.key")+`
+`), dir
+}
+
+func TestNoEmbed(t *testing.T) {
+	fname, dir := fileRefConfig(t)
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	embedded, stderr, err := runMain(t, "-f", fname, "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"certificate-authority-data: " + encode("ca"),
+		"client-certificate-data: " + encode("cert"),
+		"client-key-data: " + encode("key"),
+	} {
+		if !strings.Contains(embedded, want) {
+			t.Errorf("%q not found in:\n%s", want, embedded)
+		}
+	}
+
+	kept, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-no-embed")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"certificate-authority: " + filepath.Join(dir, "ca.crt"),
+		"client-certificate: " + filepath.Join(dir, "client.crt"),
+		"client-key: " + filepath.Join(dir, "client.key"),
+	} {
+		if !strings.Contains(kept, want) {
+			t.Errorf("%q not found in:\n%s", want, kept)
+		}
+	}
+	if strings.Contains(kept, "-data:") {
+		t.Errorf("the files are embedded:\n%s", kept)
+	}
+}
+
+func TestUserFieldOrder(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/user-fields.yaml", "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	golden, err := os.ReadFile("testdata/user-fields.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != string(golden) {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, golden)
+	}
+}
+
+func TestMinifyToBytes(t *testing.T) {
+	cfg, err := parseConfig("config", []byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MinifyToBytes(cfg, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := parseConfig("minified", data)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, data)
+	}
+	if len(single.Clusters) != 1 || single.Clusters[0].Name != "prod" ||
+		len(single.Contexts) != 1 || single.Contexts[0].Name != "prod" ||
+		len(single.Users) != 1 || single.Users[0].Name != "bob" ||
+		single.CurrentContext != "prod" {
+		t.Errorf("unexpected config:\n%s", data)
+	}
+	if len(cfg.Contexts) != 2 || cfg.CurrentContext != "dev" {
+		t.Errorf("the source config is changed: %+v", cfg)
+	}
+
+	if _, err = MinifyToBytes(cfg, "staging"); err == nil {
+		t.Error("expected an error on an unknown context")
+	}
+}
+
+func TestIndent(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-indent", "4")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := "clusters:\n    - name: dev\n      cluster:\n        server: https://dev.example.com:6443\n"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("%q not found in:\n%s", want, stdout)
+	}
+
+	for _, n := range []string{"1", "10", "two"} {
+		if _, _, err = runMain(t, "-f", fname, "-c", "dev", "-indent", n); err == nil {
+			t.Errorf("the indent %s is accepted", n)
+		}
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	cfg, err := parseConfig("config", []byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		perm, want os.FileMode
+	}{
+		{0, 0600},
+		{0640, 0640},
+	} {
+		fname := writeFile(t, dir, "config", "old")
+		if err = os.Chmod(fname, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = WriteConfig(fname, cfg, tt.perm); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != tt.want {
+			t.Errorf("%o: got the mode %o, want %o", tt.perm, mode, tt.want)
+		}
+		if data, _ := os.ReadFile(fname); !strings.Contains(string(data), "current-context: dev\n") {
+			t.Errorf("%o: unexpected content:\n%s", tt.perm, data)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
+
+func TestDocumentSeparators(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/separators.yaml", "-c", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	golden, err := os.ReadFile("testdata/separators.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != string(golden) {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout, golden)
+	}
+	if strings.Contains(stdout, "---") || strings.Contains(stdout, "...") || strings.HasSuffix(stdout, "\n\n") {
+		t.Errorf("stray document markers in:\n%q", stdout)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	pretty, stderr, err := runMain(t, "-f", "testdata/eks.yaml")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	compact, stderr, err := runMain(t, "-f", "testdata/eks.yaml", "-compact")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if strings.Count(compact, "\n") != 1 || !strings.HasPrefix(compact, "{") {
+		t.Errorf("the config is not printed on a single line:\n%s", compact)
+	}
+	if len(compact) >= len(pretty) {
+		t.Errorf("the compact output is not smaller: %d >= %d bytes", len(compact), len(pretty))
+	}
+
+	want, err := parseConfig("pretty", []byte(pretty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseConfig("compact", []byte(compact))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.doc, want.doc = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the compact output does not describe the same config:\n%s", compact)
+	}
+}
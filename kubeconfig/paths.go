@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// RelocatePaths rewrites the relative file references of the config, which
+// are relative to the directory from, to be relative to the directory to,
+// so that they still point to the same files once the config is moved
+func (c *Config) RelocatePaths(from, to string) error {
+	from, err := filepath.Abs(from)
+	if err != nil {
+		return err
+	}
+	if to, err = filepath.Abs(to); err != nil {
+		return err
+	}
+	return c.rewritePaths(func(path string) (string, error) {
+		return filepath.Rel(to, filepath.Join(from, path))
+	})
+}
+
+// AbsPaths makes the relative file references of the config, which are
+// relative to the directory from, absolute
+func (c *Config) AbsPaths(from string) error {
+	from, err := filepath.Abs(from)
+	if err != nil {
+		return err
+	}
+	return c.rewritePaths(func(path string) (string, error) {
+		return filepath.Join(from, path), nil
+	})
+}
+
+// rewritePaths replaces the relative file references with what rewrite
+// returns for them
+func (c *Config) rewritePaths(rewrite func(string) (string, error)) error {
+	var err error
+	relocate := func(path *string) {
+		if *path == "" || filepath.IsAbs(*path) || err != nil {
+			return
+		}
+		*path, err = rewrite(*path)
+	}
+	for i := range c.Clusters {
+		relocate(&c.Clusters[i].Cluster.CertificateAuthority)
+	}
+	for i := range c.Users {
+		user := &c.Users[i].User
+		relocate(&user.ClientCertificate)
+		relocate(&user.ClientKey)
+		relocate(&user.TokenFile)
+	}
+	return err
+}
+
+// Rekey re-reads the files referenced by the entries which also embed
+// their data, so that the embedded data follows the rotation of the files.
+// The entries with only a file reference are left as they are, unlike with
+// flattening. The names of the entries updated are returned
+func (c *Config) Rekey() ([]string, error) {
+	// the same ca is often referenced by many clusters
+	cache := map[string]B64{}
+	var rekeyed []string
+	rekey := func(kind, name string, data *B64, path string) error {
+		if len(*data) == 0 || path == "" {
+			return nil
+		}
+		b, ok := cache[path]
+		if !ok {
+			var err error
+			if b, err = dataOrFile(nil, path); err != nil {
+				return fmt.Errorf("unable to rekey %s %q: %w", kind, name, err)
+			}
+			cache[path] = b
+		}
+		if !bytes.Equal(*data, b) {
+			*data = b
+			// the certificate and the key of a user are reported once
+			if entry := fmt.Sprintf("%s %q", kind, name); len(rekeyed) == 0 || rekeyed[len(rekeyed)-1] != entry {
+				rekeyed = append(rekeyed, entry)
+			}
+		}
+		return nil
+	}
+	for i := range c.Clusters {
+		cluster := &c.Clusters[i]
+		if err := rekey("cluster", cluster.Name, &cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority); err != nil {
+			return nil, err
+		}
+	}
+	for i := range c.Users {
+		user := &c.Users[i]
+		if err := rekey("user", user.Name, &user.User.ClientCertificateData, user.User.ClientCertificate); err != nil {
+			return nil, err
+		}
+		if err := rekey("user", user.Name, &user.User.ClientKeyData, user.User.ClientKey); err != nil {
+			return nil, err
+		}
+	}
+	return rekeyed, nil
+}
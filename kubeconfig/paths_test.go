@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// relRefConfig writes a config referencing its certificates relatively to
+// its own directory but for the client key, in a subdirectory of the
+// returned root
+func relRefConfig(t *testing.T) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(dir, "certs"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "certs/ca.crt", "ca")
+	writeFile(t, dir, "certs/client.crt", "cert")
+	writeFile(t, dir, "certs/client.key", "key")
+	return writeFile(t, dir, "config", `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    certificate-authority: certs/ca.crt
+    server: https://dev.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+current-context: dev
+users:
+- name: alice
+  user:
+    client-certificate: certs/client.crt
+    client-key: `+filepath.Join(dir, "certs", "client.key")+`
+`), root
+}
+
+func TestRelativeReferencesAreResolvedAgainstTheConfig(t *testing.T) {
+	fname, _ := relRefConfig(t)
+	stdout, stderr, err := runMain(t, "-f", fname)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	for _, want := range []string{
+		"certificate-authority-data: " + encode("ca") + "\n",
+		"client-certificate-data: " + encode("cert") + "\n",
+		"client-key-data: " + encode("key") + "\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRelativeOut(t *testing.T) {
+	fname, root := relRefConfig(t)
+	out := filepath.Join(root, "out", "nested", "config")
+	if err := os.MkdirAll(filepath.Dir(out), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := runMain(t, "-f", fname, "-no-embed", "-relative-out", "-o", out); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"certificate-authority: ../../src/certs/ca.crt\n",
+		"client-certificate: ../../src/certs/client.crt\n",
+		"client-key: " + filepath.Join(root, "src", "certs", "client.key") + "\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%q not found in:\n%s", want, data)
+		}
+	}
+
+	if _, stderr, err := runMain(t, "-f", fname, "-relative-out", "-o", out); err == nil || !strings.Contains(stderr, "-relative-out needs -o and -no-embed or -embed-ca-only") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestSplitRelocatesReferences(t *testing.T) {
+	fname, root := relRefConfig(t)
+	dir := filepath.Join(root, "split")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err := runMain(t, "split", "-f", fname, "-no-embed", "-output-dir", dir); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "dev.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "certificate-authority: ../src/certs/ca.crt\n") {
+		t.Errorf("the reference is not relocated:\n%s", data)
+	}
+}
+
+func TestMergeRelocatesReferences(t *testing.T) {
+	fname, root := relRefConfig(t)
+	stdout, stderr, err := runMain(t, "merge", fname)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := "certificate-authority: " + filepath.Join(root, "src", "certs", "ca.crt") + "\n"; !strings.Contains(stdout, want) {
+		t.Errorf("%q not found in:\n%s", want, stdout)
+	}
+
+	out := filepath.Join(root, "merged")
+	if _, stderr, err = runMain(t, "merge", "-o", out, fname); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "certificate-authority: src/certs/ca.crt\n") {
+		t.Errorf("the reference is not relative to the output:\n%s", data)
+	}
+
+	if _, stderr, err = runMain(t, "merge", "-o", out, "-flatten", fname); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if data, _ = os.ReadFile(out); !strings.Contains(string(data), "client-certificate-data: "+base64.StdEncoding.EncodeToString([]byte("cert"))) {
+		t.Errorf("the certificate is not embedded:\n%s", data)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeFile(t, dir, "ca.crt", "rotated ca\n")
+	cert := writeFile(t, dir, "client.crt", "rotated cert\n")
+	key := writeFile(t, dir, "client.key", "old key\n")
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	fname := writeFile(t, dir, "config", `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+    certificate-authority: `+ca+`
+    certificate-authority-data: `+b64("old ca\n")+`
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+    certificate-authority: `+ca+`
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: alice
+current-context: dev
+users:
+- name: alice
+  user:
+    client-certificate: `+cert+`
+    client-certificate-data: `+b64("old cert\n")+`
+    client-key: `+key+`
+    client-key-data: `+b64("old key\n")+`
+`)
+	in := &input{fname: fname, fd: -1, format: "yaml"}
+	cfg, err := in.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekeyed, err := cfg.Rekey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(rekeyed, ", "), `cluster "dev", user "alice"`; got != want {
+		t.Errorf("got the rekeyed entries %s, want %s", got, want)
+	}
+	if got := string(cfg.FindCluster("dev").Cluster.CertificateAuthorityData); got != "rotated ca\n" {
+		t.Errorf("got the ca %q", got)
+	}
+	if got := string(cfg.FindUser("alice").User.ClientCertificateData); got != "rotated cert\n" {
+		t.Errorf("got the client certificate %q", got)
+	}
+	if cfg.FindCluster("prod").Cluster.CertificateAuthorityData != nil {
+		t.Error("the cluster with only a file reference is embedded")
+	}
+
+	_, stderr, err := runMain(t, "-f", fname, "-rekey")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, `rekeyed cluster "dev"`) || strings.Contains(stderr, `"prod"`) {
+		t.Errorf("unexpected report: %s", stderr)
+	}
+
+	if err = os.Remove(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err = runMain(t, "-f", fname, "-rekey"); err == nil || !strings.Contains(stderr, `unable to rekey user "alice"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestEmbedCAOnly(t *testing.T) {
+	fname, root := relRefConfig(t)
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	key := filepath.Join(root, "src", "certs", "client.key")
+
+	stdout, stderr, err := runMain(t, "-f", fname, "-embed-ca-only")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, want := range []string{
+		"certificate-authority-data: " + encode("ca") + "\n",
+		"client-certificate: certs/client.crt\n",
+		"client-key: " + key + "\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "client-key-data") || strings.Contains(stdout, "certificate-authority: ") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+
+	// the client references follow the output, the ca is still found
+	out := filepath.Join(root, "out", "config")
+	if err = os.Mkdir(filepath.Dir(out), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err = runMain(t, "-f", fname, "-embed-ca-only", "-o", out, "-relative-out"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	outDir := filepath.Join(root, "split")
+	if err = os.Mkdir(outDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, err = runMain(t, "split", "-f", fname, "-embed-ca-only", "-output-dir", outDir); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, fname := range []string{out, filepath.Join(outDir, "dev.yaml")} {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"certificate-authority-data: " + encode("ca") + "\n",
+			"client-certificate: ../src/certs/client.crt\n",
+			"client-key: " + key + "\n",
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: %q not found in:\n%s", fname, want, data)
+			}
+		}
+	}
+}
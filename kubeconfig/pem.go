@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeUserPEM writes the client certificate and key of the user into
+// <dir>/<user>.crt and <dir>/<user>.key, the name made usable as a file name
+func writeUserPEM(dir string, user User) error {
+	cert, err := dataOrFile(user.User.ClientCertificateData, user.User.ClientCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := dataOrFile(user.User.ClientKeyData, user.User.ClientKey)
+	if err != nil {
+		return err
+	}
+	if len(cert) == 0 || len(key) == 0 {
+		return fmt.Errorf("user %q has no client certificate", user.Name)
+	}
+	base := filepath.Join(dir, fileName(user.Name))
+	if err = ioutil.WriteFile(base+".crt", cert, 0644); err != nil {
+		return err
+	}
+	return writeKey(base+".key", key)
+}
+
+// writeKey writes a private key readable by the owner only, the mode of an
+// existing file is fixed before the key is written into it
+func writeKey(fname string, key []byte) error {
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = f.Chmod(0600); err == nil {
+		_, err = f.Write(key)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fileName makes an entry name usable as a file name, names like the eks
+// arns contain slashes
+func fileName(name string) string {
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name)
+}
+
+// writeClusterCA writes the certificate authority of the cluster as PEM,
+// reading the file it references if it is not embedded
+func writeClusterCA(w io.Writer, cfg *Config, name string) error {
+	cluster := cfg.FindCluster(name)
+	if cluster == nil {
+		return &NotFoundError{"cluster", name}
+	}
+	ca, err := dataOrFile(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority)
+	if err != nil {
+		return fmt.Errorf("unable to read certificate authority: %w", err)
+	}
+	if len(ca) == 0 {
+		return fmt.Errorf("cluster %q has no certificate authority", name)
+	}
+	if block, _ := pem.Decode(ca); block == nil {
+		return fmt.Errorf("certificate authority of cluster %q is not PEM", name)
+	}
+	_, err = w.Write(ca)
+	return err
+}
+
+func runWritePEM(args []string) error {
+	var (
+		context string
+		dir     string
+	)
+	fs := flag.NewFlagSet("write-pem", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.StringVar(&context, "c", "", "context name")
+	fs.StringVar(&dir, "d", ".", "output directory")
+	addErrorFormatFlag(fs)
+	fs.Parse(args)
+	if err := checkFormat(); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	if context == "" {
+		context = cfg.CurrentContext
+	}
+	if err = cfg.Minify(context); err != nil {
+		return err
+	}
+	if err = writeUserPEM(dir, cfg.Users[0]); err != nil {
+		return fmt.Errorf("unable to write pem files: %w", err)
+	}
+	return nil
+}
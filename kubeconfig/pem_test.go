@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWritePEM(t *testing.T) {
+	cert, key := newTestCert(t, "alice")
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certData := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	keyData := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", `clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: team/alice
+current-context: dev
+users:
+- name: team/alice
+  user:
+    client-certificate-data: `+certData+`
+    client-key-data: `+keyData+`
+`)
+	// an existing key file gets its mode fixed
+	writeFile(t, dir, "team_alice.key", "")
+	if err = os.Chmod(filepath.Join(dir, "team_alice.key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stderr, err := runMain(t, "write-pem", "-f", fname, "-d", dir); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "team_alice.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("the certificate is not PEM encoded")
+	}
+	if _, err = x509.ParseCertificate(block.Bytes); err != nil {
+		t.Error(err)
+	}
+
+	keyFile := filepath.Join(dir, "team_alice.key")
+	if data, err = os.ReadFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if block, _ = pem.Decode(data); block == nil {
+		t.Fatal("the key is not PEM encoded")
+	}
+	if _, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		t.Error(err)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("the key mode is %v, want 0600", mode)
+	}
+}
+
+func TestPrintCA(t *testing.T) {
+	ca, _ := newTestCA(t, "cluster-ca")
+	dir := t.TempDir()
+	fname := writeFile(t, dir, "config", serverConfig("https://127.0.0.1:6443", ca))
+	stdout, stderr, err := runMain(t, "-f", fname, "-print-ca", "local")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	block, rest := pem.Decode([]byte(stdout))
+	if block == nil || block.Type != "CERTIFICATE" || len(rest) != 0 {
+		t.Fatalf("the output is not a PEM certificate:\n%s", stdout)
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err != nil || !cert.Equal(ca) {
+		t.Errorf("got another certificate: %v", err)
+	}
+
+	// the certificate authority file is resolved against the config
+	writeFile(t, dir, "ca.crt", stdout)
+	fname = writeFile(t, dir, "file-ref", strings.Replace(serverConfig("https://127.0.0.1:6443", ca), "certificate-authority-data: "+pemData(ca), "certificate-authority: ca.crt", 1))
+	if got, stderr, err := runMain(t, "-f", fname, "-print-ca", "local"); err != nil || got != stdout {
+		t.Errorf("got %q, %v: %s", got, err, stderr)
+	}
+
+	if _, stderr, err := runMain(t, "-f", fname, "-print-ca", "prod"); err == nil || !strings.Contains(stderr, `unable to find cluster "prod"`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	fname = writeFile(t, dir, "no-ca", testConfig)
+	if _, stderr, err := runMain(t, "-f", fname, "-print-ca", "dev"); err == nil || !strings.Contains(stderr, `cluster "dev" has no certificate authority`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
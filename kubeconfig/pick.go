@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// pickContext lists the contexts with numbers on w and reads the chosen
+// one from r, asking again until a valid number is given
+func pickContext(r io.Reader, w io.Writer, cfg *Config) (string, error) {
+	names := cfg.ContextNames()
+	if len(names) == 0 {
+		return "", errors.New("no context to choose from")
+	}
+	for i, name := range names {
+		fmt.Fprintf(w, "%3d) %s\n", i+1, name)
+	}
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprintf(w, "context [1-%d]: ", len(names))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("no context chosen")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err == nil && n >= 1 && n <= len(names) {
+			return names[n-1], nil
+		}
+		fmt.Fprintf(w, "invalid choice %q\n", scanner.Text())
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPickContext(t *testing.T) {
+	cfg := &Config{Contexts: []Context{{Name: "dev"}, {Name: "prod"}, {Name: "staging"}}}
+	var out bytes.Buffer
+	name, err := pickContext(strings.NewReader("x\n7\n 2 \n"), &out, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "prod" {
+		t.Errorf("got %q, want prod", name)
+	}
+	for _, want := range []string{"  1) dev\n", "  3) staging\n", `invalid choice "x"`, `invalid choice "7"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%q not found in:\n%s", want, out.String())
+		}
+	}
+
+	if _, err = pickContext(strings.NewReader(""), &out, cfg); err == nil {
+		t.Error("expected an error when nothing is chosen")
+	}
+	if _, err = pickContext(strings.NewReader("1\n"), &out, &Config{}); err == nil {
+		t.Error("expected an error without contexts")
+	}
+}
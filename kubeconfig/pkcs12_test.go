@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestLoadPKCS12(t *testing.T) {
+	cert, key := newTestCert(t, "alice")
+	for _, password := range []string{"", "secret"} {
+		data, err := pkcs12.LegacyRC2.WithRand(rand.Reader).Encode(key, cert, nil, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fname := writeFile(t, t.TempDir(), "alice.p12", string(data))
+		certPEM, keyPEM, err := loadPKCS12(fname, password)
+		if err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+		if block, _ := pem.Decode(certPEM); block == nil || !bytes.Equal(block.Bytes, cert.Raw) {
+			t.Errorf("password %q: the certificate is not extracted", password)
+		}
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			t.Fatalf("password %q: the key is not PEM encoded", password)
+		}
+		if _, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			t.Errorf("password %q: %v", password, err)
+		}
+	}
+	if _, _, err := loadPKCS12(writeFile(t, t.TempDir(), "bad.p12", "garbage"), ""); err == nil {
+		t.Error("expected an error on an invalid bundle")
+	}
+}
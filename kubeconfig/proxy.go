@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// dialTLS does the tls handshake with the server at addr, through the
+// proxy of the cluster when it has one
+func dialTLS(ctx context.Context, cluster ClusterInfo, addr string, conf *tls.Config) (*tls.Conn, error) {
+	if cluster.ProxyURL == "" {
+		conn, err := (&tls.Dialer{Config: conf}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return conn.(*tls.Conn), nil
+	}
+	conn, err := dialProxy(ctx, cluster.ProxyURL, addr)
+	if err != nil {
+		return nil, err
+	}
+	if conf.ServerName == "" {
+		conf = conf.Clone()
+		conf.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, conf)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// dialProxy opens a tunnel to addr with a CONNECT request to the http or
+// https proxy, the failures are reported as the ones of the proxy
+func dialProxy(ctx context.Context, proxyURL, addr string) (net.Conn, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse proxy url: %w", err)
+	}
+	proxyErr := func(err error) error {
+		return fmt.Errorf("unable to connect through proxy %s: %w", u.Redacted(), err)
+	}
+	port := u.Port()
+	switch {
+	case u.Scheme == "http" && port == "":
+		port = "80"
+	case u.Scheme == "https" && port == "":
+		port = "443"
+	case u.Scheme != "http" && u.Scheme != "https":
+		return nil, proxyErr(fmt.Errorf("proxy scheme %q is not supported", u.Scheme))
+	}
+	proxyAddr := net.JoinHostPort(u.Hostname(), port)
+
+	var conn net.Conn
+	if u.Scheme == "https" {
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", proxyAddr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddr)
+	}
+	if err != nil {
+		return nil, proxyErr(err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+		req.Header["Proxy-Authorization"] = req.Header["Authorization"]
+		req.Header.Del("Authorization")
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, proxyErr(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, proxyErr(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, proxyErr(fmt.Errorf("proxy answered %s", resp.Status))
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{conn, r}, nil
+}
+
+// bufferedConn reads what the reader of the proxy response buffered
+// before reading the connection
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// startProxy starts an http proxy tunneling the CONNECT requests, the ones
+// without the basic credentials user:password are rejected. The number of
+// tunnels opened is counted in tunnels
+func startProxy(t *testing.T, tunnels *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Header.Set("Authorization", r.Header.Get("Proxy-Authorization"))
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		atomic.AddInt32(tunnels, 1)
+		go io.Copy(upstream, buf)
+		io.Copy(conn, upstream)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckConnectivityThroughProxy(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	var tunnels int32
+	proxy := startProxy(t, &tunnels)
+	dir := t.TempDir()
+	withProxy := func(proxyURL string) string {
+		return writeFile(t, dir, "config", strings.Replace(serverConfig(srv.URL, srv.Certificate()),
+			"    server: ", "    proxy-url: "+proxyURL+"\n    server: ", 1))
+	}
+
+	authURL := strings.Replace(proxy.URL, "http://", "http://user:password@", 1)
+	_, stderr, err := runMain(t, "-f", withProxy(authURL), "-c", "local", "-check-connectivity", "-verify-chain")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "connected to "+srv.URL) {
+		t.Errorf("the connection is not reported: %q", stderr)
+	}
+	if n := atomic.LoadInt32(&tunnels); n != 2 {
+		t.Errorf("%d tunnels opened through the proxy, want 2", n)
+	}
+
+	rejected := strings.Replace(proxy.URL, "http://", "http://user:wrong@", 1)
+	want := "unable to connect through proxy " + strings.Replace(proxy.URL, "http://", "http://user:xxxxx@", 1) + ": proxy answered 407 Proxy Authentication Required"
+	if _, stderr, err = runMain(t, "-f", withProxy(rejected), "-c", "local", "-check-connectivity"); err == nil || !strings.Contains(stderr, want) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "http://" + l.Addr().String()
+	l.Close()
+	if _, stderr, err = runMain(t, "-f", withProxy(down), "-c", "local", "-check-connectivity"); err == nil || !strings.Contains(stderr, "unable to connect through proxy "+down+": ") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err = runMain(t, "-f", withProxy("socks5://127.0.0.1:1080"), "-c", "local", "-check-connectivity"); err == nil || !strings.Contains(stderr, `proxy scheme "socks5" is not supported`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
+
+func TestSetClusterProxyURL(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "set-cluster", "-f", fname, "-proxy-url", "http://proxy.example.com:3128", "dev")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "      server: https://dev.example.com:6443\n      proxy-url: http://proxy.example.com:3128\n") {
+		t.Errorf("the proxy url is not set:\n%s", stdout)
+	}
+}
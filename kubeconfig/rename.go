@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runRename(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	in := addInputFlags(fs)
+	edit := addEditFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: rename [flags] cluster|context|user <name> <new-name>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	kind, name, newName := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	if err := edit.check(in); err != nil {
+		return err
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	// the references to the entry follow it
+	switch kind {
+	case "cluster":
+		err = cfg.RenameCluster(name, newName)
+	case "context":
+		err = cfg.RenameContext(name, newName)
+	case "user":
+		err = cfg.RenameUser(name, newName)
+	default:
+		return fmt.Errorf("unable to rename a %q, expected cluster, context or user", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	return edit.write(in, cfg)
+}
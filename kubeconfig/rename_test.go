@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	for _, args := range [][]string{
+		{"cluster", "dev", "development"},
+		{"user", "alice", "alice-dev"},
+		{"context", "dev", "dev-ctx"},
+	} {
+		if _, stderr, err := runMain(t, append([]string{"rename", "-f", fname, "-in-place"}, args...)...); err != nil {
+			t.Fatalf("%v: %s", err, stderr)
+		}
+	}
+	cfg, err := loadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := cfg.FindContext("dev-ctx")
+	if ctx == nil || ctx.Context.Cluster != "development" || ctx.Context.User != "alice-dev" {
+		t.Fatalf("the references are not updated: %+v", cfg.Contexts)
+	}
+	if cfg.FindCluster("development") == nil || cfg.FindUser("alice-dev") == nil || cfg.FindContext("dev") != nil {
+		t.Errorf("the entries are not renamed: %+v", cfg)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"cluster", "missing", "other"}, `unable to find cluster "missing"`},
+		{[]string{"user", "alice-dev", "bob"}, `user "bob" already exists`},
+		{[]string{"namespace", "a", "b"}, "expected cluster, context or user"},
+	} {
+		_, stderr, err := runMain(t, append([]string{"rename", "-f", fname}, tt.args...)...)
+		if err == nil || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: unexpected result %v: %s", tt.args, err, stderr)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var b64Type = reflect.TypeOf(B64(nil))
+
+// checkSchema walks the parsed document along the fields of the model and
+// reports the unknown fields, the values of the wrong kind and the missing
+// required fields, which the decoder silently ignores. The errors are
+// reported as "name:line: message" like the parse errors
+func (c *Config) checkSchema(name string) error {
+	if c.doc == nil {
+		return errors.New("the schema can only be checked on a yaml input")
+	}
+	root := c.doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	var errs []error
+	walkSchema(name, root, reflect.TypeOf(Config{}), "", &errs)
+	return errors.Join(errs...)
+}
+
+func walkSchema(fname string, node *yaml.Node, t reflect.Type, path string, errs *[]error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Tag == "!!null" || t.Kind() == reflect.Interface {
+		return
+	}
+	where := path
+	if where == "" {
+		where = "config"
+	}
+	report := func(line int, format string, args ...interface{}) {
+		*errs = append(*errs, fmt.Errorf("%s:%d: %s", fname, line, fmt.Sprintf(format, args...)))
+	}
+	expect := func(kind yaml.Kind, what string) bool {
+		if node.Kind != kind {
+			report(node.Line, "%s should be %s", where, what)
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case t == b64Type:
+		expect(yaml.ScalarNode, "a base64 string")
+	case t.Kind() == reflect.Struct:
+		if !expect(yaml.MappingNode, "a mapping") {
+			return
+		}
+		fields, required := schemaFields(t)
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				report(key.Line, "unknown field %q in %s", key.Value, where)
+				continue
+			}
+			seen[key.Value] = true
+			walkSchema(fname, value, field, joinPath(path, key.Value), errs)
+		}
+		for _, key := range required {
+			if !seen[key] {
+				report(node.Line, "missing field %q in %s", key, where)
+			}
+		}
+	case t.Kind() == reflect.Slice:
+		if !expect(yaml.SequenceNode, "a list") {
+			return
+		}
+		for i, item := range node.Content {
+			walkSchema(fname, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case t.Kind() == reflect.Map:
+		if !expect(yaml.MappingNode, "a mapping") {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkSchema(fname, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), errs)
+		}
+	default:
+		expect(yaml.ScalarNode, "a scalar")
+	}
+}
+
+// schemaFields returns the types of the yaml fields of t by key, and the
+// keys which are required: the names of the entries and the fields which
+// are always emitted
+func schemaFields(t reflect.Type) (map[string]reflect.Type, []string) {
+	fields := map[string]reflect.Type{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+		if name == "name" || tag != "" && !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return fields, required
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaCheckAcceptsRealConfigs(t *testing.T) {
+	authProvider := writeFile(t, t.TempDir(), "config", `apiVersion: v1
+kind: Config
+clusters:
+- name: gke
+  cluster:
+    server: https://34.1.2.3
+    certificate-authority-data: Y2EgZGF0YQ==
+contexts:
+- name: gke
+  context:
+    cluster: gke
+    user: gke
+current-context: gke
+preferences:
+  colors: true
+  extensions:
+  - name: editor
+    extension:
+      theme: dark
+users:
+- name: gke
+  user:
+    auth-provider:
+      name: gcp
+      config:
+        cmd-path: /usr/bin/gcloud
+        expiry-key: '{.credential.token_expiry}'
+    extensions:
+    - name: audit
+      extension: enabled
+`)
+	for _, fname := range []string{"testdata/minikube.yaml", "testdata/eks.yaml", "testdata/rancher.yaml", authProvider} {
+		if _, stderr, err := runMain(t, "list", "-f", fname, "-schema-check"); err != nil {
+			t.Errorf("%s: %v: %s", fname, err, stderr)
+		}
+	}
+}
+
+func TestSchemaCheckInvalidConfig(t *testing.T) {
+	// the decoder accepts the config, the misplaced fields are dropped
+	if _, stderr, err := runMain(t, "list", "-f", "testdata/invalid.yaml"); err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+
+	_, stderr, err := runMain(t, "list", "-f", "testdata/invalid.yaml", "-schema-check")
+	if err == nil {
+		t.Fatal("the invalid config is accepted")
+	}
+	for _, want := range []string{
+		`testdata/invalid.yaml:5: unknown field "server" in clusters[0]` + "\n",
+		`testdata/invalid.yaml:11: unknown field "token" in contexts[0].context` + "\n",
+		`testdata/invalid.yaml:14: missing field "name" in users[0]` + "\n",
+		`testdata/invalid.yaml:17: missing field "command" in users[0].user.exec` + "\n",
+		`testdata/invalid.yaml:19: unknown field "preference" in config` + "\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("%q not found in:\n%s", want, stderr)
+		}
+	}
+}
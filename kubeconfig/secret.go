@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type secret struct {
+	Kind       string            `yaml:"kind"`
+	Data       map[string]B64    `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// secretData returns the value of key in a Secret manifest, stringData
+// wins over data like it does when the secret is applied
+func secretData(name string, manifest []byte, key string) ([]byte, error) {
+	var s secret
+	if err := yaml.Unmarshal(manifest, &s); err != nil {
+		return nil, yamlError(name, err)
+	}
+	if s.Kind != "Secret" {
+		return nil, fmt.Errorf("%s: kind is %q, not Secret", name, s.Kind)
+	}
+	if v, ok := s.StringData[key]; ok {
+		return []byte(v), nil
+	}
+	if v, ok := s.Data[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s: secret has no %q key", name, key)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretInput(t *testing.T) {
+	for _, tt := range []struct {
+		key  string
+		want string
+	}{
+		{"", "ci:deployer-token"},
+		{"admin.conf", "ci:admin-token"},
+	} {
+		args := []string{"-f", "testdata/secret.yaml", "-secret", "-c", "ci", "-template", "{{range .Contexts}}{{.Name}}{{end}}:{{range .Users}}{{.User.Token}}{{end}}"}
+		if tt.key != "" {
+			args = append(args, "-secret-key", tt.key)
+		}
+		stdout, stderr, err := runMain(t, args...)
+		if err != nil {
+			t.Fatalf("%q: %v: %s", tt.key, err, stderr)
+		}
+		if stdout != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.key, stdout, tt.want)
+		}
+	}
+
+	if _, err := secretData("secret.yaml", []byte("kind: Secret\ndata:\n  other: eA==\n"), "kubeconfig"); err == nil || err.Error() != `secret.yaml: secret has no "kubeconfig" key` {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-secret"); err == nil || !strings.Contains(stderr, `testdata/rancher.yaml: kind is "Config", not Secret`) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+	if _, stderr, err := runMain(t, "make-insecure", "-f", "testdata/secret.yaml", "-secret", "-in-place", "-yes", "ci"); err == nil || !strings.Contains(stderr, "-in-place cannot rewrite a Secret manifest") {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+func runSplit(args []string) error {
+	var (
+		dir    string
+		nameBy string
+	)
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.StringVar(&dir, "output-dir", ".", "directory to write the configs into")
+	fs.StringVar(&nameBy, "name-by", "context", "name the files after the context or the cluster")
+	addOutputFlags(fs, true)
+	addErrorFormatFlag(fs)
+	fs.Parse(args)
+	if err := checkFormat(); err != nil {
+		return err
+	}
+	if nameBy != "context" && nameBy != "cluster" {
+		return fmt.Errorf("unable to name the files by %q", nameBy)
+	}
+
+	cfg, err := in.load()
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+
+	if noEmbed || embedCAOnly {
+		if err = cfg.RelocatePaths(configDir, dir); err != nil {
+			return fmt.Errorf("unable to relocate the file references: %w", err)
+		}
+		// the references are relative to the output directory now
+		configDir = dir
+	}
+
+	used := map[string]bool{}
+	return cfg.ForEachContext(func(ctx string, single *Config) error {
+		name := ctx
+		if nameBy == "cluster" {
+			name = single.Contexts[0].Context.Cluster
+		}
+		// the contexts sharing a cluster get an index, skipping the names
+		// of the files already written
+		base := fileName(name)
+		name = base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[name] = true
+
+		fname := filepath.Join(dir, name+".yaml")
+		if err := WriteConfig(fname, single, 0600); err != nil {
+			return fmt.Errorf("unable to write config: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const splitConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: x
+  cluster:
+    server: https://x.example.com
+- name: x-2
+  cluster:
+    server: https://x2.example.com
+contexts:
+- name: a
+  context:
+    cluster: x
+    user: alice
+- name: team/b
+  context:
+    cluster: x
+    user: alice
+- name: c
+  context:
+    cluster: x-2
+    user: alice
+users:
+- name: alice
+  user:
+    token: alice-token
+`
+
+func TestSplit(t *testing.T) {
+	for _, tt := range []struct {
+		nameBy string
+		files  map[string]string
+	}{
+		{"context", map[string]string{"a.yaml": "a", "team_b.yaml": "team/b", "c.yaml": "c"}},
+		{"cluster", map[string]string{"x.yaml": "a", "x-2.yaml": "team/b", "x-2-2.yaml": "c"}},
+	} {
+		dir := t.TempDir()
+		fname := writeFile(t, t.TempDir(), "config", splitConfig)
+		if _, stderr, err := runMain(t, "split", "-f", fname, "-output-dir", dir, "-name-by", tt.nameBy); err != nil {
+			t.Fatalf("%s: %v: %s", tt.nameBy, err, stderr)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(tt.files) {
+			t.Errorf("%s: got %d files, want %d", tt.nameBy, len(entries), len(tt.files))
+		}
+		for name, ctx := range tt.files {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("%s: %v", tt.nameBy, err)
+				continue
+			}
+			if want := "current-context: " + ctx + "\n"; !strings.Contains(string(data), want) {
+				t.Errorf("%s: %q not found in %s:\n%s", tt.nameBy, want, name, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stripped is the set of the fields removed from the printed config
+var stripped = map[string]bool{}
+
+// strippableFields returns the yaml names of the fields of the clusters,
+// contexts and users
+func strippableFields() map[string]bool {
+	fields := map[string]bool{}
+	for _, t := range []reflect.Type{
+		reflect.TypeOf(ClusterInfo{}),
+		reflect.TypeOf(ContextInfo{}),
+		reflect.TypeOf(UserInfo{}),
+	} {
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = true
+			}
+		}
+	}
+	return fields
+}
+
+func parseStrip(s string) error {
+	known := strippableFields()
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			names := make([]string, 0, len(known))
+			for name := range known {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown field %q, expecting one of %s", name, strings.Join(names, ", "))
+		}
+		stripped[name] = true
+	}
+	return nil
+}
+
+// stripFields removes the stripped fields from the clusters, contexts and
+// users of the encoded config
+func stripFields(root *yaml.Node) {
+	if len(stripped) == 0 || root.Kind != yaml.MappingNode {
+		return
+	}
+	entries := map[string]string{
+		"clusters": "cluster",
+		"contexts": "context",
+		"users":    "user",
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		info, ok := entries[root.Content[i].Value]
+		if !ok || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, entry := range root.Content[i+1].Content {
+			if entry.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(entry.Content); j += 2 {
+				if entry.Content[j].Value == info {
+					removeKeys(entry.Content[j+1])
+				}
+			}
+		}
+	}
+}
+
+func removeKeys(m *yaml.Node) {
+	if m.Kind != yaml.MappingNode {
+		return
+	}
+	content := m.Content[:0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if !stripped[m.Content[i].Value] {
+			content = append(content, m.Content[i], m.Content[i+1])
+		}
+	}
+	m.Content = content
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err := runMain(t, "-f", fname, "-c", "dev", "-strip", "token, server")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	for _, field := range []string{"token:", "server:"} {
+		if strings.Contains(stdout, field) {
+			t.Errorf("%q is kept:\n%s", field, stdout)
+		}
+	}
+	for _, want := range []string{"  - name: alice\n", "current-context: dev\n", "cluster: dev\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%q not found in:\n%s", want, stdout)
+		}
+	}
+
+	_, stderr, err = runMain(t, "-f", fname, "-c", "dev", "-strip", "secret")
+	if err == nil || !strings.Contains(stderr, `unknown field "secret", expecting one of `) {
+		t.Errorf("unexpected result %v: %s", err, stderr)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type contextSummary struct {
+	Context   string   `json:"context"`
+	Cluster   string   `json:"cluster"`
+	Server    string   `json:"server"`
+	User      string   `json:"user"`
+	Auth      []string `json:"auth"`
+	Namespace string   `json:"namespace,omitempty"`
+	Insecure  bool     `json:"insecure"`
+}
+
+// printSummary prints a one line json object describing the single
+// context of the minified config
+func printSummary(w io.Writer, cfg *Config) error {
+	if len(cfg.Contexts) != 1 {
+		return errors.New("-summary needs a single context")
+	}
+	ctx := cfg.Contexts[0]
+	cluster := cfg.FindCluster(ctx.Context.Cluster)
+	user := cfg.FindUser(ctx.Context.User)
+	auth := authMethods(user.User)
+	if auth == nil {
+		auth = []string{}
+	}
+	data, err := json.Marshal(contextSummary{
+		Context:   ctx.Name,
+		Cluster:   cluster.Name,
+		Server:    cluster.Cluster.Server,
+		User:      user.Name,
+		Auth:      auth,
+		Namespace: ctx.Context.Namespace,
+		Insecure:  cluster.Cluster.InsecureSkipTLSVerify,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
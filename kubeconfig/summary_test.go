@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	stdout, stderr, err := runMain(t, "-f", "testdata/rancher.yaml", "-c", "prod-node1", "-summary")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := `{"context":"prod-node1","cluster":"prod-node1","server":"https://10.0.0.11:6443","user":"prod","auth":["token"],"insecure":false}` + "\n"; stdout != want {
+		t.Errorf("got %s, want %s", stdout, want)
+	}
+
+	fname := writeFile(t, t.TempDir(), "config", testConfig)
+	stdout, stderr, err = runMain(t, "-f", fname, "-c", "prod", "-namespace-default", "web", "-insecure", "-summary")
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	if want := `{"context":"prod","cluster":"prod","server":"https://prod.example.com:6443","user":"bob","auth":["token"],"namespace":"web","insecure":true}` + "\n"; stdout != want {
+		t.Errorf("got %s, want %s", stdout, want)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+)
+
+// set at build time with
+//
+//	-ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+func runVersion(args []string) error {
+	_, err := fmt.Printf("kubeconfig %s (commit %s, built %s)\n", version, commit, date)
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"path"
+)
+
+// parseZip merges the configs of the archive in the order of its entries,
+// the first one defining an entry wins like with the merge command. The
+// entries which are not yaml or json files are skipped
+func parseZip(name string, data []byte) (*Config, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cfg    *Config
+		merger *Merger
+	)
+	for _, f := range r.File {
+		parse := parseConfig
+		switch path.Ext(f.Name) {
+		case ".yaml", ".yml":
+		case ".json":
+			parse = parseJSONConfig
+		default:
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readLimited(name+":"+f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		other, err := parse(name+":"+f.Name, data)
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil {
+			cfg, merger = other, NewMerger(other, false)
+			continue
+		}
+		merger.Add(other)
+		cfg.doc = nil
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("%s: no config found in the archive", name)
+	}
+	return cfg, nil
+}